@@ -0,0 +1,339 @@
+package main
+
+import (
+	"authentication-service/internal/data"
+	"authentication-service/internal/domain"
+	"authentication-service/internal/service"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// deleteAccountHandler lets users delete their own account after confirming their password
+func (app *application) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input service.DeleteAccountInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	auth := app.contextGetAuth(r)
+	match, err := app.services.UserService.CheckPassword(auth.UserID, input.Password)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	if !match {
+		app.badRequestResponse(w, r, InvalidCombinationError)
+		return
+	}
+
+	user, opErr := app.services.UserService.GetUserByID(auth.UserID)
+	if opErr != nil {
+		app.serverSideErrorResponse(w, r, opErr)
+		return
+	}
+
+	app.audit(r, auth.UserID, service.AuditAccountDeletionRequested)
+	response, err := app.deleteAccount(auth.UserID, user.Version, false)
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			app.editConflictResponse(w, r)
+			return
+		}
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusAccepted, response, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+// adminDeleteUserHandler deletes any account. With ?immediate=true the grace period is skipped.
+func (app *application) adminDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	immediate, err := strconv.ParseBool(app.readString(r.URL.Query(), "immediate", "false"))
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("immediate must be a boolean value"))
+		return
+	}
+	user, ok := app.readTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	app.audit(r, user.ID, service.AuditAccountDeletionRequested)
+	response, err := app.deleteAccount(user.ID, user.Version, immediate)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverSideErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusAccepted, response, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) restoreUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := app.readTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.services.UserService.RestoreUser(user.ID, user.Version)
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			app.editConflictResponse(w, r)
+			return
+		}
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.audit(r, user.ID, service.AuditAccountRestored)
+
+	err = app.writeJSON(w, http.StatusOK, responseData{"data": "User restored"}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+// suspendUserHandler blocks a user until they are reinstated and revokes all their tokens
+func (app *application) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input service.SuspendUserInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	user, ok := app.readTargetUser(w, r)
+	if !ok {
+		return
+	}
+	userID := user.ID
+
+	err = app.services.UserService.SuspendUser(userID, user.Version, input)
+	if err != nil {
+		var operationErrors *domain.OperationErrors
+		switch {
+		case errors.As(err, &operationErrors):
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, operationErrors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverSideErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.services.TokenService.RevokeUserTokens(userID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.services.PersonalTokenService.RevokeAllPersonalAccessTokens(userID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.logger.Warn("User suspended", "userId", userID, "reason", input.Reason)
+	app.audit(r, userID, service.AuditAccountSuspended)
+
+	err = app.writeJSON(w, http.StatusOK, responseData{"data": "User suspended"}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) reinstateUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := app.readTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.services.UserService.ReinstateUser(user.ID, user.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotSuspended):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverSideErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.audit(r, user.ID, service.AuditAccountReinstated)
+
+	err = app.writeJSON(w, http.StatusOK, responseData{"data": "User reinstated"}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+// unlockUserHandler lifts a lockout caused by failed logins before it runs out
+func (app *application) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := app.readTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.services.UserService.UnlockUser(user.ID, user.Version)
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			app.editConflictResponse(w, r)
+			return
+		}
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.audit(r, user.ID, service.AuditAccountUnlocked)
+
+	err = app.writeJSON(w, http.StatusOK, responseData{"data": "User unlocked"}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+// exportUserDataHandler returns everything stored about the authenticated user as a JSON archive
+func (app *application) exportUserDataHandler(w http.ResponseWriter, r *http.Request) {
+
+	auth := app.contextGetAuth(r)
+	app.audit(r, auth.UserID, service.AuditDataExported)
+
+	profile, err := app.loadProfile(auth.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	sessions, err := app.services.TokenService.ListSessions(auth.UserID, auth.Token.Family)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	personalTokens, err := app.services.PersonalTokenService.ListPersonalAccessTokens(auth.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	auditLog, err := app.services.AuditService.ListForUser(auth.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+
+	export := &service.UserExport{
+		ExportedAt:           time.Now(),
+		Profile:              profile,
+		Sessions:             sessions,
+		PersonalAccessTokens: personalTokens,
+		AuditLog:             auditLog,
+	}
+
+	headers := make(http.Header)
+	headers.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-export.json"`, auth.UserID))
+	err = app.writeJSON(w, http.StatusOK, export, headers)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+// readTargetUser loads the user named in the URL of an admin request and checks the If-Match
+// precondition against their version. When it reports false the response has already been sent.
+func (app *application) readTargetUser(w http.ResponseWriter, r *http.Request) (*service.UserResponse, bool) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid user ID"))
+		return nil, false
+	}
+	user, opErr := app.services.UserService.GetUserByID(userID)
+	if opErr != nil {
+		app.errorResponse(w, r, http.StatusNotFound, "user not found")
+		return nil, false
+	}
+	if !ifMatch(r, user.Version) {
+		app.preconditionFailedResponse(w, r)
+		return nil, false
+	}
+	return user, true
+}
+
+// blockedAccountError returns why a user may not sign in or use their tokens, or nil when they may
+func blockedAccountError(user *service.UserResponse) error {
+	switch user.Status {
+	case data.UserSuspended:
+		return AccountSuspendedError
+	case data.UserDeleted:
+		return AccountDeletedError
+	}
+	return nil
+}
+
+// deleteAccount schedules the account at the given version for deletion and logs the user out
+// everywhere, or deletes it right away when asked to or when no grace period is configured
+func (app *application) deleteAccount(userID int64, version int, immediate bool) (responseData, error) {
+	grace := app.config.accounts.deletionGracePeriod
+	if immediate || grace == 0 {
+		err := app.services.UserService.DeleteUser(userID, version)
+		if err != nil {
+			return nil, err
+		}
+		return responseData{"data": "Account deleted"}, nil
+	}
+
+	// scheduling first means a conflicting edit leaves the user's sessions alone
+	err := app.services.UserService.ScheduleDeletion(userID, version)
+	if err != nil {
+		return nil, err
+	}
+	err = app.services.TokenService.RevokeUserTokens(userID)
+	if err != nil {
+		return nil, err
+	}
+	err = app.services.PersonalTokenService.RevokeAllPersonalAccessTokens(userID)
+	if err != nil {
+		return nil, err
+	}
+	return responseData{
+		"data":     "Account scheduled for deletion",
+		"purge_at": time.Now().Add(grace).Format(time.RFC3339),
+	}, nil
+}
+
+// purgeDeletedUsers removes accounts whose grace period has passed, checking every interval
+func (app *application) purgeDeletedUsers(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := app.services.UserService.PurgeDeletedUsers(time.Now().Add(-app.config.accounts.deletionGracePeriod))
+		if err != nil {
+			app.logger.Error("Could not purge deleted users", "error", err)
+		} else if purged > 0 {
+			app.logger.Info("Purged deleted users", "count", purged)
+		}
+		<-ticker.C
+	}
+}
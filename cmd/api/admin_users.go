@@ -0,0 +1,49 @@
+package main
+
+import (
+	"authentication-service/internal/data"
+	"authentication-service/internal/domain"
+	"fmt"
+	"net/http"
+)
+
+// listUsersHandler serves GET /v1/admin/users. It accepts page, page_size, sort, activated, status,
+// email and name substrings, created_after, created_before and permission as query parameters.
+func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+
+	qs := r.URL.Query()
+	validationErrors := &domain.OperationErrors{}
+
+	filter := data.UserFilter{
+		Activated:     app.readBool(qs, "activated", validationErrors),
+		Status:        data.UserStatus(app.readString(qs, "status", "")),
+		Email:         app.readString(qs, "email", ""),
+		Name:          app.readString(qs, "name", ""),
+		CreatedAfter:  app.readTime(qs, "created_after", validationErrors),
+		CreatedBefore: app.readTime(qs, "created_before", validationErrors),
+		Permission:    app.readString(qs, "permission", ""),
+		Sort:          app.readString(qs, "sort", "id"),
+		Page:          app.readInt(qs, "page", 1, validationErrors),
+		PageSize:      app.readInt(qs, "page_size", 20, validationErrors),
+	}
+	if len(validationErrors.Validation) > 0 {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, validationErrors)
+		return
+	}
+
+	users, metadata, operationErrors := app.services.UserService.ListUsers(filter)
+	if operationErrors != nil {
+		if len(operationErrors.Validation) > 0 {
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, operationErrors)
+			return
+		}
+		app.serverSideErrorResponse(w, r, fmt.Errorf("could not list users: %v", operationErrors.Database))
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, responseData{"users": users, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
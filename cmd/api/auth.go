@@ -4,9 +4,10 @@ import (
 	"authentication-service/internal/data"
 	"authentication-service/internal/domain"
 	"authentication-service/internal/service"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net/http"
-	"time"
 )
 
 func (app *application) validateEmailHandler(w http.ResponseWriter, r *http.Request) {
@@ -17,23 +18,25 @@ func (app *application) validateEmailHandler(w http.ResponseWriter, r *http.Requ
 		app.badRequestResponse(w, r, MissingVerificationTokenError)
 		return
 	}
-	fmt.Printf("Validate Email token: %s\n", tokenString)
 
 	// Validate the token
-	validToken, err := app.services.TokenService.ValidateToken(tokenString, app.config.tokenConfig.secret)
+	validToken, err := app.services.TokenService.ValidateToken(tokenString, data.ActivateEmailToken)
 	if err != nil {
 		app.errorResponse(w, r, http.StatusUnauthorized, err.Error())
-		_ = app.services.TokenService.DeleteToken([]byte(tokenString)) // Delete invalid token
+		// Tokens of another scope, like access tokens, are still valid for their own purpose
+		if !errors.Is(err, service.ErrWrongTokenScope) {
+			_ = app.services.TokenService.DeleteToken(data.HashToken(tokenString)) // Delete invalid token
+		}
 		return
 	}
 	if !validToken {
 		app.errorResponse(w, r, http.StatusUnauthorized, err.Error())
-		_ = app.services.TokenService.DeleteToken([]byte(tokenString)) // Delete invalid token
+		_ = app.services.TokenService.DeleteToken(data.HashToken(tokenString)) // Delete invalid token
 		return
 	}
 
 	// Extract userId from the token
-	userId, err := app.ExtractUserIdFromToken(tokenString, app.config.tokenConfig.secret)
+	userId, err := app.ExtractUserIdFromToken(tokenString)
 	if err != nil {
 		app.errorResponse(w, r, http.StatusUnauthorized, "Missing or Invalid Token")
 		return
@@ -49,9 +52,9 @@ func (app *application) validateEmailHandler(w http.ResponseWriter, r *http.Requ
 
 	// Check if the valid token exists in the list of user tokens
 	var validInput bool
+	tokenHash := data.HashToken(tokenString)
 	for _, token := range tokens {
-		fmt.Printf("Validate Email tokens found for this user: %s\n", token.Hash)
-		if string(token.Hash) == tokenString {
+		if subtle.ConstantTimeCompare(token.Hash, tokenHash) == 1 {
 			validInput = true
 			break
 		}
@@ -94,7 +97,15 @@ func (app *application) loginHandler(w http.ResponseWriter, r *http.Request) {
 
 	user, opErr := app.services.UserService.GetUserByEmail(input.Email)
 	if opErr != nil {
-		app.serverSideErrorResponse(w, r, err)
+		domain.MatchNoAccount(input.Password)
+		app.badRequestResponse(w, r, InvalidCombinationError)
+		return
+	}
+
+	// A locked account is refused before the password is looked at, so the
+	// response is the same whether or not the password was right
+	if until, locked := app.services.UserService.IsLocked(user); locked {
+		app.accountLockedResponse(w, r, until)
 		return
 	}
 
@@ -108,33 +119,112 @@ func (app *application) loginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !match {
+		until, locked, err := app.services.UserService.RecordFailedLogin(user.ID)
+		if err != nil {
+			app.serverSideErrorResponse(w, r, err)
+			return
+		}
+		if locked {
+			app.logger.Warn("Account locked after failed logins", "userId", user.ID, "lockedUntil", until)
+			app.audit(r, user.ID, service.AuditAccountLocked)
+			app.accountLockedResponse(w, r, until)
+			return
+		}
 		app.badRequestResponse(w, r, InvalidCombinationError)
 		return
 	}
-	err = app.services.TokenService.DeleteTokensForUser(user.ID, data.UserAccessToken)
+	if user.FailedLogins > 0 {
+		err = app.services.UserService.ResetFailedLogins(user.ID)
+		if err != nil {
+			app.serverSideErrorResponse(w, r, err)
+			return
+		}
+	}
+	if err := blockedAccountError(user); err != nil {
+		app.errorResponse(w, r, http.StatusForbidden, err.Error())
+		return
+	}
+	step, err := app.services.UserService.EmailVerificationStatus(user)
+	if err != nil {
+		app.emailNotVerifiedResponse(w, r, step, err)
+		return
+	}
+	// Every login is its own session, so signing in on one device leaves the others alone
+	session, err := app.services.TokenService.CreateSession(user.ID, r.UserAgent(), app.clientIP(r))
 	if err != nil {
 		app.serverSideErrorResponse(w, r, err)
 		return
 	}
-	token, err := app.services.TokenService.CreateAccessToken(user.ID, data.UserAccessToken, app.config.tokenConfig.ttl, app.config.tokenConfig.secret)
+	res, err := app.services.TokenService.CreateTokenPair(user.ID, session.ID,
+		app.config.tokenConfig.accessTTL,
+		app.config.tokenConfig.refreshTTL)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	if step != nil {
+		res.PendingSteps = append(res.PendingSteps, *step)
+	}
+	app.audit(r, user.ID, service.AuditLogin)
 
+	err = app.writeJSON(w, http.StatusCreated, res, nil)
 	if err != nil {
 		app.serverSideErrorResponse(w, r, err)
 		return
 	}
-	dataToken := &data.Token{
-		Hash:   []byte(token),
-		UserID: user.ID,
-		Expiry: time.Now().Add(app.config.tokenConfig.ttl),
-		Scope:  data.UserAccessToken,
+}
+
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input service.RefreshTokenInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.RefreshToken == "" {
+		app.badRequestResponse(w, r, MissingRefreshTokenError)
+		return
 	}
-	_, err = app.services.TokenService.InsertToken(dataToken)
+
+	oldToken, err := app.services.TokenService.UseRefreshToken(input.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRefreshTokenReused):
+			app.logger.Warn("Refresh token reuse detected, token family revoked", "error", err)
+			app.errorResponse(w, r, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, service.ErrInvalidRefreshToken):
+			app.errorResponse(w, r, http.StatusUnauthorized, err.Error())
+		default:
+			app.serverSideErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, opErr := app.services.UserService.GetUserByID(oldToken.UserID)
+	if opErr != nil {
+		app.serverSideErrorResponse(w, r, opErr)
+		return
+	}
+	if err := blockedAccountError(user); err != nil {
+		app.errorResponse(w, r, http.StatusForbidden, err.Error())
+		return
+	}
+	step, err := app.services.UserService.EmailVerificationStatus(user)
+	if err != nil {
+		app.emailNotVerifiedResponse(w, r, step, err)
+		return
+	}
+
+	res, err := app.services.TokenService.CreateTokenPair(oldToken.UserID, oldToken.Family,
+		app.config.tokenConfig.accessTTL,
+		app.config.tokenConfig.refreshTTL)
 	if err != nil {
 		app.serverSideErrorResponse(w, r, err)
 		return
 	}
-	res := &service.LoginInResponse{
-		AuthorizationToken: token,
+	if step != nil {
+		res.PendingSteps = append(res.PendingSteps, *step)
 	}
 	err = app.writeJSON(w, http.StatusCreated, res, nil)
 	if err != nil {
@@ -142,3 +232,49 @@ func (app *application) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+
+	auth := app.contextGetAuth(r)
+	err := app.services.TokenService.RevokeToken(auth.Token)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.audit(r, auth.UserID, service.AuditLogout)
+
+	response := responseData{
+		"data": "Logged out successfully",
+	}
+	err = app.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+
+	auth := app.contextGetAuth(r)
+	err := app.services.TokenService.RevokeUserTokens(auth.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	// Logging out everywhere also signs out scripts holding a personal access token
+	err = app.services.PersonalTokenService.RevokeAllPersonalAccessTokens(auth.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.audit(r, auth.UserID, service.AuditLogoutAll)
+
+	response := responseData{
+		"data": "Logged out of all sessions successfully",
+	}
+	err = app.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
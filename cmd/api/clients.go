@@ -0,0 +1,69 @@
+package main
+
+import (
+	"authentication-service/internal/data"
+	"authentication-service/internal/service"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strings"
+)
+
+func (app *application) createClientHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input service.CreateClientInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if strings.TrimSpace(input.Name) == "" {
+		app.badRequestResponse(w, r, errors.New("name must not be empty"))
+		return
+	}
+
+	client, err := app.services.ClientService.CreateClient(input.Name)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusCreated, client, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) listClientsHandler(w http.ResponseWriter, r *http.Request) {
+
+	clients, err := app.services.ClientService.ListClients()
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, responseData{"clients": clients}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) deleteClientHandler(w http.ResponseWriter, r *http.Request) {
+
+	clientID := chi.URLParam(r, "clientID")
+
+	err := app.services.ClientService.DeleteClient(clientID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.errorResponse(w, r, http.StatusNotFound, "client not found")
+			return
+		}
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, responseData{"data": "Client deleted"}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
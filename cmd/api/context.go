@@ -0,0 +1,34 @@
+package main
+
+import (
+	"authentication-service/internal/data"
+	"context"
+	"net/http"
+)
+
+type contextKey string
+
+const authContextKey = contextKey("auth")
+
+// authContext describes who made an authenticated request and with which token.
+// Exactly one of Token and PersonalToken is set.
+type authContext struct {
+	UserID        int64
+	Token         *data.Token
+	PersonalToken *data.PersonalAccessToken
+	// Permissions holds the permissions claimed by the token, nil when they have to be looked up
+	Permissions data.Permissions
+}
+
+func (app *application) contextSetAuth(r *http.Request, auth *authContext) *http.Request {
+	ctx := context.WithValue(r.Context(), authContextKey, auth)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetAuth(r *http.Request) *authContext {
+	auth, ok := r.Context().Value(authContextKey).(*authContext)
+	if !ok {
+		panic("missing auth value in request context")
+	}
+	return auth
+}
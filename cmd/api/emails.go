@@ -0,0 +1,173 @@
+package main
+
+import (
+	"authentication-service/internal/data"
+	"authentication-service/internal/service"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func (app *application) changeEmailHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input service.ChangeEmailInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	auth := app.contextGetAuth(r)
+	user, operationErrors := app.services.UserService.RequestEmailChange(auth.UserID, input)
+	if operationErrors != nil {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, operationErrors)
+		return
+	}
+
+	ttl, cancelTTL := app.config.tokenConfig.ttl, app.config.tokenConfig.cancelTTL
+	confirmToken, err := app.services.TokenService.CreateOneTimeToken(user.ID, data.EmailChangeToken, ttl)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	// cancellation tokens sent earlier keep working, they all went to the address the account still has
+	cancelToken, err := app.services.TokenService.AddOneTimeToken(user.ID, data.EmailChangeCancelToken, cancelTTL)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+
+	app.sendEmail(user.PendingEmail, "Confirm your new email address", fmt.Sprintf(
+		"Hi %s,\n\nUse the following token to confirm %s as the new email address of your account:\n\n%s\n\n"+
+			"It expires in %s.\n", user.Name, user.PendingEmail, confirmToken, ttl))
+	app.sendEmail(user.Email, "Your email address is being changed", fmt.Sprintf(
+		"Hi %s,\n\nSomeone asked to change the email address of your account to %s.\n\n"+
+			"If this was not you, use the following token to cancel the change, you will also be logged out everywhere:\n\n%s\n\n"+
+			"It keeps working after the new address is confirmed and expires in %s.\n", user.Name, user.PendingEmail, cancelToken, cancelTTL))
+
+	app.audit(r, user.ID, service.AuditEmailChangeRequested)
+
+	response := responseData{
+		"data": "A confirmation has been sent to the new email address",
+	}
+	err = app.writeJSON(w, http.StatusAccepted, response, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+
+	token, ok := app.readEmailChangeToken(w, r, data.EmailChangeToken)
+	if !ok {
+		return
+	}
+
+	user, err := app.services.UserService.ConfirmEmailChange(token.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNoPendingEmailChange):
+			app.badRequestResponse(w, r, InvalidEmailChangeTokenError)
+		case errors.Is(err, service.ErrEmailTaken):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverSideErrorResponse(w, r, err)
+		}
+		return
+	}
+	// the cancellation token stays valid, the owner of the old address may still undo the change
+	err = app.services.TokenService.DeleteTokensForUser(user.ID, data.EmailChangeToken)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.audit(r, user.ID, service.AuditEmailChanged)
+
+	err = app.writeJSON(w, http.StatusOK, responseData{"data": "Email address changed successfully"}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+// cancelEmailChangeHandler is used from the old address, before or after the new one was confirmed.
+// A change the owner did not ask for means someone else is signed in, so every session and
+// personal access token of the account is revoked as well.
+func (app *application) cancelEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+
+	token, ok := app.readEmailChangeToken(w, r, data.EmailChangeCancelToken)
+	if !ok {
+		return
+	}
+
+	err := app.services.UserService.CancelEmailChange(token.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNoPendingEmailChange):
+			app.badRequestResponse(w, r, InvalidEmailChangeTokenError)
+		case errors.Is(err, service.ErrEmailTaken):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverSideErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.deleteEmailChangeTokens(token.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.services.TokenService.RevokeUserTokens(token.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.services.PersonalTokenService.RevokeAllPersonalAccessTokens(token.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.audit(r, token.UserID, service.AuditEmailChangeCancelled)
+
+	err = app.writeJSON(w, http.StatusOK, responseData{"data": "Email change cancelled"}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+// readEmailChangeToken reads the token from the request body and spends it, writing
+// the error response itself when the token is missing, invalid or already used
+func (app *application) readEmailChangeToken(w http.ResponseWriter, r *http.Request, scope data.TokenScope) (*data.Token, bool) {
+	var input service.EmailChangeTokenInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+	if input.Token == "" {
+		app.badRequestResponse(w, r, MissingEmailChangeTokenError)
+		return nil, false
+	}
+
+	token, err := app.services.TokenService.ConsumeOneTimeToken(input.Token, scope)
+	if err != nil {
+		if errors.Is(err, service.ErrTokenRevoked) || errors.Is(err, service.ErrWrongTokenScope) {
+			app.badRequestResponse(w, r, InvalidEmailChangeTokenError)
+			return nil, false
+		}
+		app.serverSideErrorResponse(w, r, err)
+		return nil, false
+	}
+	return token, true
+}
+
+// deleteEmailChangeTokens spends both the confirmation and the cancellation token
+func (app *application) deleteEmailChangeTokens(userID int64) error {
+	err := app.services.TokenService.DeleteTokensForUser(userID, data.EmailChangeToken)
+	if err != nil {
+		return err
+	}
+	return app.services.TokenService.DeleteTokensForUser(userID, data.EmailChangeCancelToken)
+}
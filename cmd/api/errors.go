@@ -1,14 +1,30 @@
 package main
 
 import (
+	"authentication-service/internal/service"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 var InvalidCombinationError = errors.New("invalid combination")
 var MissingAuthTokenError = errors.New("Missing authorization token")
+var InvalidAuthTokenError = errors.New("Invalid or expired authorization token")
 var MissingVerificationTokenError = errors.New("Missing verification token")
+var MissingRefreshTokenError = errors.New("Missing refresh token")
+var MissingResetTokenError = errors.New("Missing password reset token")
+var InvalidResetTokenError = errors.New("Invalid or expired password reset token")
+var MissingEmailChangeTokenError = errors.New("Missing email change token")
+var InvalidEmailChangeTokenError = errors.New("Invalid or expired email change token")
+var AccountDeletedError = errors.New("This account is scheduled for deletion")
+var AccountSuspendedError = errors.New("This account has been suspended")
+var AccountLockedError = errors.New("This account is temporarily locked after too many failed login attempts")
+var SessionRequiredError = errors.New("This action requires a login session, personal access tokens are not accepted")
+var MissingVersionError = errors.New("The version being updated must be sent as an If-Match header or in the request body")
+var InvalidIfMatchError = errors.New("If-Match must hold a single version tag")
 
 func (app *application) logError(r *http.Request, err error) {
 	var method = r.Method
@@ -43,7 +59,28 @@ func (app *application) routeResourceNotAllowedResponse(w http.ResponseWriter, r
 	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
 }
 
+func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
+	message := "unable to update the record due to an edit conflict, please try again"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has been modified since it was read, fetch it again and retry"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
 	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
 }
+
+// accountLockedResponse tells the client when it may try to log in again
+func (app *application) accountLockedResponse(w http.ResponseWriter, r *http.Request, until time.Time) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(until).Seconds()))))
+	app.errorResponse(w, r, http.StatusLocked, AccountLockedError.Error())
+}
+
+// emailNotVerifiedResponse refuses a user who has yet to verify their email address and names the missing step
+func (app *application) emailNotVerifiedResponse(w http.ResponseWriter, r *http.Request, step *service.PendingStep, err error) {
+	app.errorResponse(w, r, http.StatusForbidden, responseData{"message": err.Error(), "missing_step": step})
+}
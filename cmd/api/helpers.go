@@ -1,13 +1,17 @@
 package main
 
 import (
+	"authentication-service/internal/domain"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/golang-jwt/jwt/v4"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func (app *application) writeJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
@@ -97,29 +101,140 @@ func (app *application) GetAuthStringFromHeader(w http.ResponseWriter, r *http.R
 	return tokenString, nil
 }
 
-func (app *application) ExtractUserIdFromToken(tokenString string, secret string) (int64, error) {
-	var secretKey = []byte(secret)
+func (app *application) ExtractUserIdFromToken(tokenString string) (int64, error) {
+	return app.services.TokenService.ExtractUserID(tokenString)
+}
+
+// clientIP returns the address of the client that opened the connection
+func (app *application) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if token.Method != jwt.SigningMethodHS256 {
-			return nil, fmt.Errorf("invalid signing method")
+// background runs fn in its own goroutine, logging instead of crashing if it panics
+func (app *application) background(fn func()) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error(fmt.Sprintf("%v", err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// sendEmail delivers an email in the background so that slow mail servers do not hold up the response
+func (app *application) sendEmail(recipient, subject, body string) {
+	app.background(func() {
+		err := app.mailer.Send(recipient, subject, body)
+		if err != nil {
+			app.logger.Error("Could not send email", "subject", subject, "error", err)
 		}
-		return secretKey, nil
 	})
+}
+
+// readString returns a query string value, or the default when it is missing
+func (app *application) readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+// readInt returns a query string value as an integer, recording a validation error when it is not one
+func (app *application) readInt(qs url.Values, key string, defaultValue int, ve *domain.OperationErrors) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		ve.AddValidationError(key, "must be an integer value")
+		return defaultValue
+	}
+	return i
+}
+
+// readBool returns a query string value as a boolean, nil when it is missing
+func (app *application) readBool(qs url.Values, key string, ve *domain.OperationErrors) *bool {
+	s := qs.Get(key)
+	if s == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		ve.AddValidationError(key, "must be a boolean value")
+		return nil
+	}
+	return &b
+}
 
-	if err != nil || !token.Valid {
-		return 0, fmt.Errorf("invalid or expired token")
+// readTime returns an RFC 3339 query string value as a time, nil when it is missing
+func (app *application) readTime(qs url.Values, key string, ve *domain.OperationErrors) *time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return nil
 	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		ve.AddValidationError(key, "must be an RFC 3339 timestamp")
+		return nil
+	}
+	return &t
+}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return 0, fmt.Errorf("invalid claims")
+// versionETag derives the strong ETag of a record from its version
+func versionETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ifMatch evaluates the If-Match precondition of a request against the current version of a
+// record (RFC 9110 section 13.1.1). Requests without the header always pass.
+func ifMatch(r *http.Request, version int) bool {
+	header := strings.Join(r.Header.Values("If-Match"), ",")
+	if header == "" {
+		return true
 	}
 
-	userID, ok := claims["sub"].(float64)
-	if !ok {
-		return 0, fmt.Errorf("userID is missing or invalid in token")
+	current := versionETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		// weak tags never match under the strong comparison If-Match requires
+		if tag == "*" || tag == current {
+			return true
+		}
 	}
+	return false
+}
 
-	return int64(userID), nil
+// ifMatchVersion returns the version named by the If-Match header of a request, for updates
+// that have to know the version up front. present is false without the header or for "*".
+func ifMatchVersion(r *http.Request) (version int, present bool, err error) {
+	header := strings.TrimSpace(strings.Join(r.Header.Values("If-Match"), ","))
+	if header == "" || header == "*" {
+		return 0, false, nil
+	}
+
+	tag, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, false, InvalidIfMatchError
+	}
+	version, err = strconv.Atoi(tag)
+	if err != nil {
+		return 0, false, InvalidIfMatchError
+	}
+	return version, true, nil
+}
+
+// audit records an action in the user's audit log. A failure is logged but does not fail the request.
+func (app *application) audit(r *http.Request, userID int64, action string) {
+	err := app.services.AuditService.Record(userID, action, app.clientIP(r), r.UserAgent())
+	if err != nil {
+		app.logger.Error("Could not record audit entry", "userId", userID, "action", action, "error", err)
+	}
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"authentication-service/internal/data"
+	"authentication-service/internal/service"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"time"
+)
+
+func (app *application) listSigningKeysHandler(w http.ResponseWriter, r *http.Request) {
+
+	keys, err := app.services.TokenService.ListSigningKeys()
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, responseData{"keys": keys}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) rotateSigningKeyHandler(w http.ResponseWriter, r *http.Request) {
+
+	key, err := app.services.TokenService.RotateSigningKey()
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.logger.Info("Signing key rotated", "kid", key.ID, "algorithm", key.Method.Alg())
+
+	response := responseData{
+		"kid":       key.ID,
+		"algorithm": key.Method.Alg(),
+	}
+	err = app.writeJSON(w, http.StatusCreated, response, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) retireSigningKeyHandler(w http.ResponseWriter, r *http.Request) {
+
+	kid := chi.URLParam(r, "kid")
+
+	err := app.services.TokenService.RetireSigningKey(kid)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrActiveSigningKey):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusNotFound, "signing key not found")
+		default:
+			app.serverSideErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.logger.Info("Signing key retired", "kid", kid)
+
+	err = app.writeJSON(w, http.StatusOK, responseData{"data": "Signing key retired"}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+// reloadSigningKeys refreshes the key ring every interval, so that keys rotated or retired
+// through another instance are used and refused here as well
+func (app *application) reloadSigningKeys(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+		err := app.services.TokenService.LoadSigningKeys()
+		if err != nil {
+			app.logger.Error("Could not reload signing keys", "error", err)
+		}
+	}
+}
@@ -2,14 +2,22 @@ package main
 
 import (
 	"authentication-service/internal/data"
+	"authentication-service/internal/domain"
+	"authentication-service/internal/mailer"
 	"authentication-service/internal/service"
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"flag"
+	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"log/slog"
 	"os"
+	"slices"
+	"strings"
 	"time"
+	// profile time zones are checked against the embedded database when the host has none
+	_ "time/tzdata"
 )
 
 type config struct {
@@ -21,18 +29,73 @@ type config struct {
 		allowUnknownFields bool
 	}
 	tokenConfig struct {
-		secret string
-		ttl    time.Duration
+		secret     string
+		algorithm  string
+		privateKey string
+		keyKEK     string
+		retireKeys time.Duration
+		clientID   string
+		format     string
+		issuer     string
+		audience   string
+		leeway     time.Duration
+		ttl        time.Duration
+		accessTTL  time.Duration
+		refreshTTL time.Duration
+		resetTTL   time.Duration
+		cancelTTL  time.Duration
+
+		embedPermissions bool
+		embedRoles       bool
+		trustClaims      bool
 	}
 
 	db struct {
 		dsn string
 	}
+
+	accounts struct {
+		deletionGracePeriod time.Duration
+	}
+
+	verification struct {
+		policy      string
+		gracePeriod time.Duration
+		permissions string
+	}
+
+	emails struct {
+		foldLocalPart bool
+	}
+
+	passwords struct {
+		minLength      int
+		maxLength      int
+		require        string
+		rejectPersonal bool
+		denyList       string
+	}
+
+	lockout struct {
+		threshold   int
+		duration    time.Duration
+		maxDuration time.Duration
+		window      time.Duration
+	}
+
+	smtp struct {
+		host     string
+		port     int
+		username string
+		password string
+		sender   string
+	}
 }
 type application struct {
 	config   config
 	logger   *slog.Logger
 	services *service.ServiceManager
+	mailer   mailer.Mailer
 }
 
 const version = "1.0.0"
@@ -50,16 +113,125 @@ func main() {
 
 	flag.StringVar(&cfg.db.dsn, "db-dsn", "./database.db", "SQLITE3 DSN")
 
-	flag.StringVar(&cfg.tokenConfig.secret, "secret", "defaultSecret", "The secret key for token signing")
-	flag.DurationVar(&cfg.tokenConfig.ttl, "ttl", 3*24*time.Hour, "The time-to-live for the token")
+	flag.StringVar(&cfg.tokenConfig.secret, "secret", "defaultSecret", "The secret key for HS256 token signing")
+	flag.StringVar(&cfg.tokenConfig.algorithm, "jwt-alg", "HS256", "JWT signing algorithm (HS256|RS256|ES256|EdDSA)")
+	flag.StringVar(&cfg.tokenConfig.privateKey, "jwt-private-key", "", "Path to the PEM encoded private key for RS256, ES256 and EdDSA")
+	flag.StringVar(&cfg.tokenConfig.keyKEK, "signing-key-kek", os.Getenv("SIGNING_KEY_KEK"), "Base64 encoded 32 byte key that encrypts signing keys in the database, defaults to $SIGNING_KEY_KEK")
+	flag.StringVar(&cfg.tokenConfig.format, "token-format", service.JWTFormat, "Format of issued tokens (jwt|opaque)")
+	flag.BoolVar(&cfg.tokenConfig.embedPermissions, "jwt-embed-permissions", false, "Include the user's permissions as a claim in access tokens")
+	flag.BoolVar(&cfg.tokenConfig.embedRoles, "jwt-embed-roles", false, "Include the user's roles as a claim in access tokens")
+	flag.BoolVar(&cfg.tokenConfig.trustClaims, "jwt-trust-permission-claims", true, "Authorize with the permissions claim when present instead of looking them up in the database")
+	flag.StringVar(&cfg.tokenConfig.issuer, "jwt-issuer", "authentication-service", "Issuer (iss) written to and required on every JWT")
+	flag.StringVar(&cfg.tokenConfig.audience, "jwt-audience", "authentication-service", "Audience (aud) written to and required on every JWT")
+	flag.DurationVar(&cfg.tokenConfig.leeway, "jwt-leeway", 30*time.Second, "Clock skew tolerated when checking the exp, nbf and iat claims")
+	flag.StringVar(&cfg.tokenConfig.clientID, "client-id", "authentication-service", "Client ID recorded in the tokens issued by this service")
+	flag.DurationVar(&cfg.tokenConfig.retireKeys, "jwt-key-retire-after", 3*24*time.Hour, "How long a rotated signing key is still accepted for verification")
+	flag.DurationVar(&cfg.tokenConfig.ttl, "ttl", 3*24*time.Hour, "The time-to-live for the email verification token")
+	flag.DurationVar(&cfg.tokenConfig.accessTTL, "access-ttl", 15*time.Minute, "The time-to-live for the access token")
+	flag.DurationVar(&cfg.tokenConfig.refreshTTL, "refresh-ttl", 7*24*time.Hour, "The time-to-live for the refresh token")
+	flag.DurationVar(&cfg.tokenConfig.resetTTL, "password-reset-ttl", 30*time.Minute, "The time-to-live for the password reset token")
+	flag.DurationVar(&cfg.tokenConfig.cancelTTL, "email-change-cancel-ttl", 14*24*time.Hour, "How long the old address can cancel an email change, also after it was confirmed")
+
+	flag.DurationVar(&cfg.accounts.deletionGracePeriod, "deletion-grace-period", 30*24*time.Hour, "How long a deleted account can still be restored before it is removed for good")
+
+	flag.StringVar(&cfg.verification.policy, "email-verification", service.VerificationOff, "Policy for users who have not verified their email address (off|grace|strict)")
+	flag.DurationVar(&cfg.verification.gracePeriod, "email-verification-grace", 7*24*time.Hour, "How long after registering unverified users can sign in with the grace policy")
+	flag.StringVar(&cfg.verification.permissions, "unverified-permissions", "", "Comma separated permissions unverified users keep with the grace policy")
+
+	flag.BoolVar(&cfg.emails.foldLocalPart, "email-fold-local-part", true, "Treat the part of email addresses before the @ as case-insensitive")
+
+	flag.IntVar(&cfg.passwords.minLength, "password-min-length", 8, "Minimum password length in characters")
+	flag.IntVar(&cfg.passwords.maxLength, "password-max-length", domain.BcryptMaxBytes, "Maximum password length in bytes, bcrypt ignores anything past 72")
+	flag.StringVar(&cfg.passwords.require, "password-require", "", "Comma separated character classes passwords need (upper,lower,digit,symbol)")
+	flag.BoolVar(&cfg.passwords.rejectPersonal, "password-reject-personal", true, "Reject passwords containing the user's email address or name")
+	flag.StringVar(&cfg.passwords.denyList, "password-deny-list", "", "Path to a file of common or breached passwords to reject, one per line")
+
+	flag.IntVar(&cfg.lockout.threshold, "lockout-threshold", 5, "Consecutive failed logins that lock an account, 0 disables lockouts")
+	flag.DurationVar(&cfg.lockout.duration, "lockout-duration", time.Minute, "How long the first lockout lasts, it doubles with every further failed login")
+	flag.DurationVar(&cfg.lockout.maxDuration, "lockout-max-duration", 24*time.Hour, "Upper limit for a single lockout")
+	flag.DurationVar(&cfg.lockout.window, "lockout-window", 15*time.Minute, "How long after the last failed login, or the end of a lockout, the count starts over")
+
+	flag.StringVar(&cfg.smtp.host, "smtp-host", "", "SMTP host, emails are only logged when empty")
+	flag.IntVar(&cfg.smtp.port, "smtp-port", 587, "SMTP port")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", "", "SMTP username")
+	flag.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
+	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Authentication Service <no-reply@localhost>", "SMTP sender")
 
 	flag.Parse()
 
+	if cfg.tokenConfig.format != service.JWTFormat && cfg.tokenConfig.format != service.OpaqueFormat {
+		fmt.Fprintf(os.Stderr, "invalid -token-format %q, expected jwt or opaque\n", cfg.tokenConfig.format)
+		os.Exit(2)
+	}
+	if cfg.verification.policy != service.VerificationOff && cfg.verification.policy != service.VerificationGrace &&
+		cfg.verification.policy != service.VerificationStrict {
+		fmt.Fprintf(os.Stderr, "invalid -email-verification %q, expected off, grace or strict\n", cfg.verification.policy)
+		os.Exit(2)
+	}
+	if cfg.passwords.minLength < 1 || cfg.passwords.maxLength < cfg.passwords.minLength || cfg.passwords.maxLength > domain.BcryptMaxBytes {
+		fmt.Fprintf(os.Stderr, "invalid password lengths, expected 1 <= -password-min-length <= -password-max-length <= %d\n", domain.BcryptMaxBytes)
+		os.Exit(2)
+	}
+	requiredClasses := splitList(cfg.passwords.require)
+	for _, class := range requiredClasses {
+		if !slices.Contains(domain.CharacterClasses, class) {
+			fmt.Fprintf(os.Stderr, "invalid -password-require class %q, expected upper, lower, digit or symbol\n", class)
+			os.Exit(2)
+		}
+	}
+	if cfg.accounts.deletionGracePeriod < 0 {
+		fmt.Fprintf(os.Stderr, "invalid -deletion-grace-period %s, must not be negative\n", cfg.accounts.deletionGracePeriod)
+		os.Exit(2)
+	}
+	if cfg.lockout.threshold < 0 || cfg.lockout.duration <= 0 || cfg.lockout.maxDuration < cfg.lockout.duration || cfg.lockout.window < 0 {
+		fmt.Fprintln(os.Stderr, "invalid lockout settings, -lockout-duration must be positive and at most -lockout-max-duration, the others must not be negative")
+		os.Exit(2)
+	}
+	if cfg.tokenConfig.leeway < 0 {
+		fmt.Fprintf(os.Stderr, "invalid -jwt-leeway %s, must not be negative\n", cfg.tokenConfig.leeway)
+		os.Exit(2)
+	}
+	var keyEncryptionKey []byte
+	if cfg.tokenConfig.keyKEK != "" {
+		var err error
+		keyEncryptionKey, err = base64.StdEncoding.DecodeString(cfg.tokenConfig.keyKEK)
+		if err != nil || len(keyEncryptionKey) != 32 {
+			fmt.Fprintln(os.Stderr, "invalid -signing-key-kek, expected 32 bytes encoded as standard base64")
+			os.Exit(2)
+		}
+	} else if cfg.env == "production" {
+		fmt.Fprintln(os.Stderr, "-signing-key-kek or $SIGNING_KEY_KEK is required in production, signing keys would be stored unencrypted")
+		os.Exit(2)
+	}
+
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	if keyEncryptionKey == nil {
+		logger.Warn("No key encryption key configured, signing keys are stored unencrypted")
+	}
+
+	passwordPolicy := &domain.PasswordPolicy{
+		MinLength:          cfg.passwords.minLength,
+		MaxLength:          cfg.passwords.maxLength,
+		RequiredClasses:    requiredClasses,
+		RejectPersonalInfo: cfg.passwords.rejectPersonal,
+	}
+	if cfg.passwords.denyList != "" {
+		denyList, err := domain.LoadPasswordDenyList(cfg.passwords.denyList)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		passwordPolicy.DenyList = denyList
+		logger.Info("Loaded password deny list", "count", len(passwordPolicy.DenyList))
+	}
 
 	app := &application{
 		config: cfg,
 		logger: logger,
+		mailer: mailer.NewLogMailer(logger),
+	}
+	if cfg.smtp.host != "" {
+		app.mailer = mailer.NewSMTPMailer(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)
 	}
 	db, err := openDB(cfg)
 	if err != nil {
@@ -68,18 +240,97 @@ func main() {
 	}
 	defer db.Close()
 
+	signingKey, err := loadSigningKey(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	userRepo := data.NewUserRepository(db)
 	tokenRepo := data.NewTokenRepository(db)
+
+	// Tokens used to be stored in plain text, those left from before the upgrade are hashed
+	// before serving so they keep working. Once they are all hashed this finds nothing to do.
+	hashed, err := tokenRepo.HashPlaintextTokens()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	if hashed > 0 {
+		logger.Info("Hashed plain text tokens", "count", hashed)
+	}
+
 	permissionsRepo := data.NewPermissionsRepository(db)
-	repoManager := data.NewRepoManager(userRepo, tokenRepo, permissionsRepo)
+	signingKeyRepo := data.NewSigningKeyRepository(db)
+	clientRepo := data.NewClientRepository(db)
+	sessionRepo := data.NewSessionRepository(db)
+	personalTokenRepo := data.NewPersonalAccessTokenRepository(db)
+	auditRepo := data.NewAuditRepository(db)
+	repoManager := data.NewRepoManager(userRepo, tokenRepo, permissionsRepo, signingKeyRepo, clientRepo, sessionRepo, personalTokenRepo, auditRepo)
+
+	userService := service.NewUserService(repoManager, service.UserConfig{
+		LockoutThreshold:   cfg.lockout.threshold,
+		LockoutDuration:    cfg.lockout.duration,
+		LockoutMaxDuration: cfg.lockout.maxDuration,
+		LockoutWindow:      cfg.lockout.window,
+
+		EmailVerification:       cfg.verification.policy,
+		VerificationGracePeriod: cfg.verification.gracePeriod,
+		UnverifiedPermissions:   splitList(cfg.verification.permissions),
+		FoldEmailLocalPart:      cfg.emails.foldLocalPart,
+		PasswordPolicy:          passwordPolicy,
+	})
+
+	// Emails used to be stored as typed, those left from before the upgrade are normalized before
+	// serving so lookups find them. Accounts that would end up sharing an address have to be
+	// resolved by hand first, serving them would let either one sign in as the other.
+	report, err := userService.NormalizeStoredEmails()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	if report.Updated > 0 {
+		logger.Info("Normalized stored email addresses", "count", report.Updated)
+	}
+	for _, userID := range report.Invalid {
+		logger.Warn("Email address has an invalid domain, correct it by hand", "userId", userID)
+	}
+	for email, userIDs := range report.Duplicates {
+		logger.Error("Accounts share an email address once it is normalized, merge or rename them by hand", "email", email, "userIds", userIDs)
+	}
+	if len(report.Duplicates) > 0 {
+		os.Exit(1)
+	}
 
-	userService := service.NewUserService(repoManager)
-	tokenService := service.NewTokenService(repoManager)
+	tokenService := service.NewTokenService(repoManager, userService, service.TokenConfig{
+		Format:           cfg.tokenConfig.format,
+		KeyRetireAfter:   cfg.tokenConfig.retireKeys,
+		ClientID:         cfg.tokenConfig.clientID,
+		Issuer:           cfg.tokenConfig.issuer,
+		Audience:         cfg.tokenConfig.audience,
+		Leeway:           cfg.tokenConfig.leeway,
+		EmbedPermissions: cfg.tokenConfig.embedPermissions,
+		EmbedRoles:       cfg.tokenConfig.embedRoles,
+		KeyEncryptionKey: keyEncryptionKey,
+	})
 	permissionsService := service.NewPermissionsService(repoManager)
+	clientService := service.NewClientService(repoManager)
+	personalTokenService := service.NewPersonalAccessTokenService(repoManager)
+	auditService := service.NewAuditService(repoManager)
 
-	serviceManager := service.NewServiceManager(userService, tokenService, permissionsService)
+	err = tokenService.ImportSigningKey(signingKey)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	serviceManager := service.NewServiceManager(userService, tokenService, permissionsService, clientService, personalTokenService, auditService)
 	app.services = serviceManager
 
+	app.background(func() { app.purgeDeletedUsers(time.Hour) })
+	app.background(func() { app.pruneSessions(time.Hour) })
+	app.background(func() { app.reloadSigningKeys(30 * time.Second) })
+
 	err = app.serve()
 	logger.Error(err.Error())
 
@@ -103,3 +354,21 @@ func openDB(cfg config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// splitList parses a comma separated list, leaving out empty entries
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func loadSigningKey(cfg config) (*service.SigningKey, error) {
+	if cfg.tokenConfig.algorithm == "HS256" {
+		return service.NewHMACSigningKey(cfg.tokenConfig.secret)
+	}
+	return service.LoadSigningKey(cfg.tokenConfig.algorithm, cfg.tokenConfig.privateKey)
+}
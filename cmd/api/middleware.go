@@ -1,42 +1,189 @@
 package main
 
 import (
+	"authentication-service/internal/data"
+	"authentication-service/internal/service"
+	"errors"
 	"net/http"
+	"net/url"
 )
 
-func (app *application) PermissionsValidation(next http.Handler) http.Handler {
+// Authenticate checks the bearer token on the request, including that it has not been revoked,
+// and stores the authenticated user in the request context
+func (app *application) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		tokenString, err := app.GetAuthStringFromHeader(w, r, "Authorization")
 		if err != nil {
-			app.errorResponse(w, r, http.StatusUnauthorized, MissingAuthTokenError)
+			app.errorResponse(w, r, http.StatusUnauthorized, MissingAuthTokenError.Error())
 			return
 		}
-		app.logger.Info("Getting token", "token", tokenString)
-		valid, err := app.services.TokenService.ValidateToken(tokenString, app.config.tokenConfig.secret)
-		if err != nil {
-			app.errorResponse(w, r, http.StatusUnauthorized, MissingAuthTokenError)
+		if service.IsPersonalAccessToken(tokenString) {
+			app.authenticatePersonalAccessToken(w, r, next, tokenString)
+			return
+		}
+		valid, err := app.services.TokenService.ValidateToken(tokenString, data.UserAccessToken)
+		if err != nil || !valid {
+			app.errorResponse(w, r, http.StatusUnauthorized, InvalidAuthTokenError.Error())
 			return
 		}
-		app.logger.Info("Validate token", "valid", valid)
-		userId, err := app.ExtractUserIdFromToken(tokenString, app.config.tokenConfig.secret)
+		// The stored token is authoritative for who the token belongs to,
+		// opaque tokens carry no claims at all
+		token, err := app.services.TokenService.GetActiveToken(tokenString, data.UserAccessToken)
 		if err != nil {
-			app.errorResponse(w, r, http.StatusUnauthorized, err)
+			if errors.Is(err, service.ErrTokenRevoked) || errors.Is(err, service.ErrWrongTokenScope) {
+				app.errorResponse(w, r, http.StatusUnauthorized, err.Error())
+				return
+			}
+			app.serverSideErrorResponse(w, r, err)
 			return
 		}
-		app.logger.Info("Getting user ID", "userId", userId)
-		permissions, err := app.services.PermissionsService.GetPermissionsForUser(userId)
+		app.logger.Info("Authenticated request", "userId", token.UserID)
+
+		err = app.services.TokenService.TouchSession(token.Family)
 		if err != nil {
 			app.serverSideErrorResponse(w, r, err)
 			return
 		}
-		app.logger.Info("Validate token", "UsersPermissions", permissions)
-		hasPermission := permissions.HasPermission("permissions:write")
-		if !hasPermission {
 
-			app.errorResponse(w, r, http.StatusUnauthorized, nil)
+		auth := &authContext{UserID: token.UserID, Token: token}
+		if app.config.tokenConfig.trustClaims {
+			if permissions, ok := app.services.TokenService.ClaimedPermissions(tokenString); ok {
+				auth.Permissions = permissions
+			}
+		}
+
+		r = app.contextSetAuth(r, auth)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticatePersonalAccessToken stores the owner of a personal access token in the request context,
+// limited to the token's own permissions
+func (app *application) authenticatePersonalAccessToken(w http.ResponseWriter, r *http.Request, next http.Handler, tokenString string) {
+	token, permissions, err := app.services.PersonalTokenService.AuthenticatePersonalAccessToken(tokenString)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPersonalAccessToken) {
+			app.errorResponse(w, r, http.StatusUnauthorized, InvalidAuthTokenError.Error())
+			return
+		}
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.logger.Info("Authenticated request with personal access token", "userId", token.UserID, "tokenId", token.ID)
+
+	r = app.contextSetAuth(r, &authContext{UserID: token.UserID, PersonalToken: token, Permissions: permissions})
+	next.ServeHTTP(w, r)
+}
+
+// RequireSession rejects requests authenticated with a personal access token. It is used for
+// actions tied to a login session, like logging out or creating further tokens.
+func (app *application) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.contextGetAuth(r).Token == nil {
+			app.errorResponse(w, r, http.StatusForbidden, SessionRequiredError.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireActiveAccount rejects requests of users who are suspended, scheduled for deletion or
+// not allowed to sign in before verifying their email address
+func (app *application) RequireActiveAccount(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := app.activeAccount(w, r); !ok {
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// activeAccount loads the authenticated user and checks that they may use their tokens. When they
+// may not, the response has already been written and false is returned.
+func (app *application) activeAccount(w http.ResponseWriter, r *http.Request) (*service.UserResponse, bool) {
+	user, opErr := app.services.UserService.GetUserByID(app.contextGetAuth(r).UserID)
+	if opErr != nil {
+		app.serverSideErrorResponse(w, r, opErr)
+		return nil, false
+	}
+	if err := blockedAccountError(user); err != nil {
+		app.errorResponse(w, r, http.StatusForbidden, err.Error())
+		return nil, false
+	}
+	if step, err := app.services.UserService.EmailVerificationStatus(user); err != nil {
+		app.emailNotVerifiedResponse(w, r, step, err)
+		return nil, false
+	}
+	return user, true
+}
+
+func (app *application) PermissionsValidation(next http.Handler) http.Handler {
+	return app.RequirePermission("permissions:write")(next)
+}
+
+// RequirePermission only lets requests through whose bearer token belongs to a user holding the given permission
+func (app *application) RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return app.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			auth := app.contextGetAuth(r)
+			user, ok := app.activeAccount(w, r)
+			if !ok {
+				return
+			}
+
+			permissions := auth.Permissions
+			if permissions == nil {
+				var err error
+				permissions, err = app.services.PermissionsService.GetPermissionsForUser(auth.UserID)
+				if err != nil {
+					app.serverSideErrorResponse(w, r, err)
+					return
+				}
+			}
+			permissions = app.services.UserService.RestrictPermissions(user, permissions)
+			app.logger.Info("Validate token", "UsersPermissions", permissions)
+			hasPermission := permissions.HasPermission(permission)
+			if !hasPermission {
+
+				app.errorResponse(w, r, http.StatusUnauthorized, nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// AuthenticateClient only lets through callers presenting valid client credentials, either with
+// HTTP Basic authentication or as client_id and client_secret form parameters (RFC 6749 section 2.3.1)
+func (app *application) AuthenticateClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		r.Body = http.MaxBytesReader(w, r.Body, int64(app.config.jsonConfig.maxByte))
+
+		clientID, secret, ok := r.BasicAuth()
+		if ok {
+			// credentials are form encoded before they are put in the header
+			clientID, _ = url.QueryUnescape(clientID)
+			secret, _ = url.QueryUnescape(secret)
+		} else {
+			clientID = r.PostFormValue("client_id")
+			secret = r.PostFormValue("client_secret")
+		}
+
+		client, err := app.services.ClientService.AuthenticateClient(clientID, secret)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidClient) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="authentication-service"`)
+				app.errorResponse(w, r, http.StatusUnauthorized, "invalid_client")
+				return
+			}
+			app.serverSideErrorResponse(w, r, err)
+			return
+		}
+		app.logger.Info("Authenticated client", "clientId", client.ID)
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"authentication-service/internal/data"
+	"authentication-service/internal/service"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input service.ForgotPasswordInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// The response is the same whether or not the email belongs to an account,
+	// so this endpoint cannot be used to find out who is registered
+	response := responseData{
+		"data": "If an account exists for this email, password reset instructions have been sent to it",
+	}
+
+	user, opErr := app.services.UserService.GetUserByEmail(input.Email)
+	if opErr == nil && user.DeletedAt == nil {
+		token, err := app.services.TokenService.CreateOneTimeToken(user.ID, data.PasswordResetToken, app.config.tokenConfig.resetTTL)
+		if err != nil {
+			app.serverSideErrorResponse(w, r, err)
+			return
+		}
+
+		body := fmt.Sprintf("Hi %s,\n\nUse the following token to reset your password:\n\n%s\n\n"+
+			"It expires in %s. If you did not ask to reset your password you can ignore this email.\n",
+			user.Name, token, app.config.tokenConfig.resetTTL)
+		app.sendEmail(user.Email, "Reset your password", body)
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, response, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input service.ResetPasswordInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.Token == "" {
+		app.badRequestResponse(w, r, MissingResetTokenError)
+		return
+	}
+
+	token, err := app.services.TokenService.GetActiveToken(input.Token, data.PasswordResetToken)
+	if err != nil {
+		if errors.Is(err, service.ErrTokenRevoked) || errors.Is(err, service.ErrWrongTokenScope) {
+			app.badRequestResponse(w, r, InvalidResetTokenError)
+			return
+		}
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+
+	// A password the policy rejects leaves the token unspent, so the user can try another one
+	operationErrors := app.services.UserService.ValidateNewPassword(token.UserID, input.Password)
+	if operationErrors != nil {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, operationErrors)
+		return
+	}
+
+	// Spending the token and checking it was still unspent is one step, so of two
+	// requests racing with the same token only one gets to set the password
+	_, err = app.services.TokenService.ConsumeOneTimeToken(input.Token, data.PasswordResetToken)
+	if err != nil {
+		if errors.Is(err, service.ErrTokenRevoked) || errors.Is(err, service.ErrWrongTokenScope) {
+			app.badRequestResponse(w, r, InvalidResetTokenError)
+			return
+		}
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	operationErrors = app.services.UserService.SetPassword(token.UserID, input.Password)
+	if operationErrors != nil {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, operationErrors)
+		return
+	}
+	err = app.services.TokenService.DeleteTokensForUser(token.UserID, data.PasswordResetToken)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.services.TokenService.RevokeUserTokens(token.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.services.PersonalTokenService.RevokeAllPersonalAccessTokens(token.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.audit(r, token.UserID, service.AuditPasswordReset)
+
+	response := responseData{
+		"data": "Password reset successfully, please log in again",
+	}
+	err = app.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input service.ChangePasswordInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	auth := app.contextGetAuth(r)
+	operationErrors := app.services.UserService.ChangePassword(auth.UserID, input)
+	if operationErrors != nil {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, operationErrors)
+		return
+	}
+
+	// A pending reset must not be able to undo the change
+	err = app.services.TokenService.DeleteTokensForUser(auth.UserID, data.PasswordResetToken)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	if input.KeepCurrentSession {
+		err = app.services.TokenService.RevokeOtherSessions(auth.UserID, auth.Token.Family)
+	} else {
+		err = app.services.TokenService.RevokeUserTokens(auth.UserID)
+	}
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	// Personal access tokens outlive sessions, so they go even when the current session is kept
+	err = app.services.PersonalTokenService.RevokeAllPersonalAccessTokens(auth.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.audit(r, auth.UserID, service.AuditPasswordChanged)
+
+	response := responseData{
+		"data": "Password changed successfully",
+	}
+	err = app.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+// passwordPolicyHandler describes the rules new passwords have to follow, so UIs can show them up front
+func (app *application) passwordPolicyHandler(w http.ResponseWriter, r *http.Request) {
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "public, max-age=300")
+
+	err := app.writeJSON(w, http.StatusOK, app.services.UserService.PasswordPolicy(), headers)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+	}
+}
@@ -83,3 +83,58 @@ func (app *application) RemovePermissionFromUserHandler(w http.ResponseWriter, r
 	}
 
 }
+
+func (app *application) AddRoleToUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	userIDStr := chi.URLParam(r, "userID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64) // Convert the string to int64
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var input service.RoleInput
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	err = app.services.PermissionsService.AddRoleToUser(userID, input.Role)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusCreated, nil, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+
+}
+
+func (app *application) RemoveRoleFromUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	userIDStr := chi.URLParam(r, "userID")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64) // Convert the string to int64
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var input service.RoleInput
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.services.PermissionsService.RemoveRole(userID, input.Role)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusCreated, nil, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+
+}
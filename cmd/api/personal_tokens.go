@@ -0,0 +1,85 @@
+package main
+
+import (
+	"authentication-service/internal/data"
+	"authentication-service/internal/service"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func (app *application) createPersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input service.CreatePersonalAccessTokenInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if strings.TrimSpace(input.Name) == "" {
+		app.badRequestResponse(w, r, errors.New("name must not be empty"))
+		return
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		app.badRequestResponse(w, r, errors.New("expires_at must be in the future"))
+		return
+	}
+
+	auth := app.contextGetAuth(r)
+	token, err := app.services.PersonalTokenService.CreatePersonalAccessToken(auth.UserID, input)
+	if err != nil {
+		if errors.Is(err, service.ErrPermissionNotHeld) {
+			app.errorResponse(w, r, http.StatusForbidden, err.Error())
+			return
+		}
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.audit(r, auth.UserID, service.AuditPersonalTokenCreated)
+
+	err = app.writeJSON(w, http.StatusCreated, token, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) listPersonalAccessTokensHandler(w http.ResponseWriter, r *http.Request) {
+
+	auth := app.contextGetAuth(r)
+	tokens, err := app.services.PersonalTokenService.ListPersonalAccessTokens(auth.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, responseData{"tokens": tokens}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) deletePersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+
+	auth := app.contextGetAuth(r)
+	tokenID := chi.URLParam(r, "tokenID")
+
+	err := app.services.PersonalTokenService.RevokePersonalAccessToken(auth.UserID, tokenID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.errorResponse(w, r, http.StatusNotFound, "token not found")
+			return
+		}
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	app.audit(r, auth.UserID, service.AuditPersonalTokenRevoked)
+
+	err = app.writeJSON(w, http.StatusOK, responseData{"data": "Token revoked"}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
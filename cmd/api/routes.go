@@ -16,6 +16,7 @@ func (app *application) routes() http.Handler {
 	r.MethodNotAllowed(app.routeResourceNotAllowedResponse)
 
 	r.Get("/healthcheck", app.healthcheckHandler)
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
 
 	r.Route("/v1", func(r chi.Router) {
 		r.Post("/users", app.registerUserHandler)
@@ -25,16 +26,85 @@ func (app *application) routes() http.Handler {
 
 		r.Post("/auth/validateEmail", app.validateEmailHandler)
 		r.Post("/auth/login", app.loginHandler)
+		r.Post("/auth/refresh", app.refreshTokenHandler)
+		r.Post("/auth/password/forgot", app.forgotPasswordHandler)
+		r.Post("/auth/password/reset", app.resetPasswordHandler)
+		r.Get("/auth/password-policy", app.passwordPolicyHandler)
+		r.Post("/auth/email/confirm", app.confirmEmailChangeHandler)
+		r.Post("/auth/email/cancel", app.cancelEmailChangeHandler)
 
 		r.Post("/tokens/email", app.RegenerateEmailTokenHandler)
 		r.Post("/tokens/validate", app.ValidateTokenHandler)
 
+		r.With(app.AuthenticateClient).Post("/oauth/introspect", app.introspectTokenHandler)
+
+		r.Group(func(r chi.Router) {
+			r.Use(app.Authenticate)
+			r.Use(app.RequireSession)
+
+			r.Post("/auth/logout", app.logoutHandler)
+			r.Post("/auth/logout/all", app.logoutAllHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.RequireActiveAccount)
+
+				r.Get("/users/me", app.getProfileHandler)
+				r.Patch("/users/me", app.updateProfileHandler)
+				r.Delete("/users/me", app.deleteAccountHandler)
+				r.Get("/users/me/export", app.exportUserDataHandler)
+
+				r.Post("/users/me/password", app.changePasswordHandler)
+				r.Post("/users/me/email", app.changeEmailHandler)
+
+				r.Get("/users/me/sessions", app.listSessionsHandler)
+				r.Delete("/users/me/sessions/{sessionID}", app.deleteSessionHandler)
+
+				r.Get("/users/me/tokens", app.listPersonalAccessTokensHandler)
+				r.Post("/users/me/tokens", app.createPersonalAccessTokenHandler)
+				r.Delete("/users/me/tokens/{tokenID}", app.deletePersonalAccessTokenHandler)
+			})
+		})
+
 		r.Group(func(r chi.Router) {
 			r.Use(app.PermissionsValidation)
 
 			r.Post("/permissions", app.AddPermissionHandler)
 			r.Post("/users/{userID}/permissions", app.AddPermissionToUserHandler)
 			r.Delete("/users/{userID}/permissions", app.RemovePermissionFromUserHandler)
+			r.Post("/users/{userID}/roles", app.AddRoleToUserHandler)
+			r.Delete("/users/{userID}/roles", app.RemoveRoleFromUserHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(app.RequirePermission("keys:write"))
+
+			r.Get("/admin/keys", app.listSigningKeysHandler)
+			r.Post("/admin/keys/rotate", app.rotateSigningKeyHandler)
+			r.Delete("/admin/keys/{kid}", app.retireSigningKeyHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(app.RequirePermission("users:read"))
+
+			r.Get("/admin/users", app.listUsersHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(app.RequirePermission("users:write"))
+
+			r.Delete("/admin/users/{userID}", app.adminDeleteUserHandler)
+			r.Post("/admin/users/{userID}/restore", app.restoreUserHandler)
+			r.Post("/admin/users/{userID}/unlock", app.unlockUserHandler)
+			r.Post("/admin/users/{userID}/suspend", app.suspendUserHandler)
+			r.Post("/admin/users/{userID}/reinstate", app.reinstateUserHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(app.RequirePermission("clients:write"))
+
+			r.Get("/admin/clients", app.listClientsHandler)
+			r.Post("/admin/clients", app.createClientHandler)
+			r.Delete("/admin/clients/{clientID}", app.deleteClientHandler)
 		})
 
 	})
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"authentication-service/internal/data"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"time"
+)
+
+func (app *application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	auth := app.contextGetAuth(r)
+	sessions, err := app.services.TokenService.ListSessions(auth.UserID, auth.Token.Family)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, responseData{"sessions": sessions}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+
+	auth := app.contextGetAuth(r)
+	sessionID := chi.URLParam(r, "sessionID")
+
+	err := app.services.TokenService.RevokeSession(auth.UserID, sessionID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.errorResponse(w, r, http.StatusNotFound, "session not found")
+			return
+		}
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, responseData{"data": "Session revoked"}, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+// pruneSessions removes sessions that can no longer be continued, checking every interval
+func (app *application) pruneSessions(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pruned, err := app.services.TokenService.PruneSessions()
+		if err != nil {
+			app.logger.Error("Could not prune sessions", "error", err)
+		} else if pruned > 0 {
+			app.logger.Info("Pruned sessions", "count", pruned)
+		}
+		<-ticker.C
+	}
+}
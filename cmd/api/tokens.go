@@ -3,6 +3,7 @@ package main
 import (
 	"authentication-service/internal/data"
 	"authentication-service/internal/service"
+	"errors"
 	"net/http"
 	"time"
 )
@@ -32,19 +33,18 @@ func (app *application) RegenerateEmailTokenHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
-	newToken, err := app.services.TokenService.CreateAccessToken(res.ID, data.ActivateEmailToken, app.config.tokenConfig.ttl, app.config.tokenConfig.secret)
+	newToken, err := app.services.TokenService.CreateAccessToken(res.ID, data.ActivateEmailToken, app.config.tokenConfig.ttl)
 	if err != nil {
 		app.serverSideErrorResponse(w, r, err)
 		return
 	}
 	res.Token = newToken
 	dataToken := &data.Token{
-		Hash:   []byte(newToken),
+		Hash:   data.HashToken(newToken),
 		UserID: res.ID,
 		Expiry: time.Now().Add(app.config.tokenConfig.ttl),
 		Scope:  data.ActivateEmailToken,
 	}
-	app.logger.Info("Regenerate Email token", "Token", dataToken)
 	_, err = app.services.TokenService.InsertToken(dataToken)
 	if err != nil {
 		app.serverSideErrorResponse(w, r, err)
@@ -65,21 +65,78 @@ func (app *application) ValidateTokenHandler(w http.ResponseWriter, r *http.Requ
 		app.badRequestResponse(w, r, err)
 		return
 	}
-	app.logger.Info("Validate token", "input", input)
-	valid, err := app.services.TokenService.ValidateToken(input.Token, app.config.tokenConfig.secret)
-	if err != nil {
+	// anything wrong with the token itself is an answer, only a failing lookup is an error
+	valid, err := app.services.TokenService.ValidateToken(input.Token, data.UserAccessToken)
+	if err != nil && !errors.Is(err, service.ErrWrongTokenScope) && !errors.Is(err, service.ErrTokenRevoked) &&
+		!errors.Is(err, service.ErrInvalidClaims) && !errors.Is(err, service.ErrInvalidToken) {
 		app.serverSideErrorResponse(w, r, err)
 		return
 	}
+	if valid {
+		// a correctly signed token may still have been revoked
+		_, err = app.services.TokenService.GetActiveToken(input.Token, data.UserAccessToken)
+		if err != nil && !errors.Is(err, service.ErrTokenRevoked) && !errors.Is(err, service.ErrWrongTokenScope) {
+			app.serverSideErrorResponse(w, r, err)
+			return
+		}
+		valid = err == nil
+	}
 	app.logger.Info("Validate token", "valid", valid)
 	response := &service.ValidateTokenResponse{
-		Token:   input.Token,
 		IsValid: valid,
 	}
-	err = app.writeJSON(w, http.StatusCreated, response, nil)
+	err = app.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+
+}
+
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "public, max-age=300")
+
+	err := app.writeJSON(w, http.StatusOK, app.services.TokenService.JWKS(), headers)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) introspectTokenHandler(w http.ResponseWriter, r *http.Request) {
+
+	tokenString := r.PostFormValue("token")
+	if tokenString == "" {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	response, err := app.services.TokenService.Introspect(tokenString)
 	if err != nil {
 		app.serverSideErrorResponse(w, r, err)
 		return
 	}
+	if response.Active {
+		user, opErr := app.services.UserService.GetUserByID(response.UserID)
+		if opErr != nil {
+			app.serverSideErrorResponse(w, r, opErr)
+			return
+		}
+		permissions, err := app.services.PermissionsService.GetPermissionsForUser(response.UserID)
+		if err != nil {
+			app.serverSideErrorResponse(w, r, err)
+			return
+		}
+		response.Permissions = app.services.UserService.RestrictPermissions(user, permissions)
+	}
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "no-store")
 
+	err = app.writeJSON(w, http.StatusOK, response, headers)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
 }
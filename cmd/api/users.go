@@ -2,7 +2,10 @@ package main
 
 import (
 	"authentication-service/internal/data"
+	"authentication-service/internal/domain"
 	"authentication-service/internal/service"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 )
@@ -24,13 +27,12 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 	jwt, err := app.services.TokenService.CreateAccessToken(userResponse.ID,
 		data.ActivateEmailToken,
-		app.config.tokenConfig.ttl,
-		app.config.tokenConfig.secret)
+		app.config.tokenConfig.ttl)
 	if err != nil {
 		app.errorResponse(w, r, http.StatusUnprocessableEntity, err)
 	}
 	dataToken := &data.Token{
-		Hash:   []byte(jwt),
+		Hash:   data.HashToken(jwt),
 		UserID: userResponse.ID,
 		Expiry: time.Now().Add(app.config.tokenConfig.ttl),
 		Scope:  data.ActivateEmailToken,
@@ -56,9 +58,33 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	operationErrors := app.services.UserService.UpdateUser(&input)
-	if operationErrors != nil {
-		app.errorResponse(w, r, http.StatusUnprocessableEntity, operationErrors)
+	// If-Match wins over the version in the body, one of the two is needed to detect conflicts
+	version, fromHeader, err := ifMatchVersion(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if !fromHeader {
+		if input.Version == nil {
+			app.badRequestResponse(w, r, MissingVersionError)
+			return
+		}
+		version = *input.Version
+	}
+
+	err = app.services.UserService.UpdateUser(&input, version)
+	if err != nil {
+		var operationErrors *domain.OperationErrors
+		switch {
+		case errors.As(err, &operationErrors):
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, operationErrors)
+		case errors.Is(err, data.ErrEditConflict) && fromHeader:
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverSideErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -73,3 +99,113 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 	}
 
 }
+
+func (app *application) getProfileHandler(w http.ResponseWriter, r *http.Request) {
+
+	auth := app.contextGetAuth(r)
+	profile, err := app.loadProfile(auth.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("ETag", versionETag(profile.Version))
+
+	err = app.writeJSON(w, http.StatusOK, profile, headers)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input service.UpdateProfileInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	auth := app.contextGetAuth(r)
+	user, opErr := app.services.UserService.GetUserByID(auth.UserID)
+	if opErr != nil {
+		app.serverSideErrorResponse(w, r, opErr)
+		return
+	}
+	if !ifMatch(r, user.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	err = app.services.UserService.UpdateProfile(auth.UserID, user.Version, input)
+	if err != nil {
+		var operationErrors *domain.OperationErrors
+		switch {
+		case errors.As(err, &operationErrors):
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, operationErrors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverSideErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	profile, err := app.loadProfile(auth.UserID)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("ETag", versionETag(profile.Version))
+
+	err = app.writeJSON(w, http.StatusOK, profile, headers)
+	if err != nil {
+		app.serverSideErrorResponse(w, r, err)
+		return
+	}
+}
+
+// loadProfile gathers the user together with their permissions and roles
+func (app *application) loadProfile(userID int64) (*service.ProfileResponse, error) {
+	user, opErr := app.services.UserService.GetUserByID(userID)
+	if opErr != nil {
+		return nil, fmt.Errorf("could not retrieve user: %v", opErr.Database)
+	}
+	permissions, err := app.services.PermissionsService.GetPermissionsForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := app.services.PermissionsService.GetRolesForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	// the profile shows what the user can actually do, not what they will have once verified
+	permissions = app.services.UserService.RestrictPermissions(user, permissions)
+	if permissions == nil {
+		permissions = data.Permissions{}
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+
+	return &service.ProfileResponse{
+		ID:           user.ID,
+		Name:         user.Name,
+		GivenName:    user.GivenName,
+		FamilyName:   user.FamilyName,
+		DisplayName:  user.DisplayName,
+		Locale:       user.Locale,
+		TimeZone:     user.TimeZone,
+		Email:        user.Email,
+		PendingEmail: user.PendingEmail,
+		Activated:    user.Activated,
+		CreatedAt:    user.CreatedAt,
+		Version:      user.Version,
+		Permissions:  permissions,
+		Roles:        roles,
+		Status:       user.Status,
+		DeletedAt:    user.DeletedAt,
+	}, nil
+}
@@ -4,10 +4,10 @@ go 1.22
 
 require (
 	github.com/go-chi/chi/v5 v5.2.0
+	github.com/golang-jwt/jwt/v4 v4.5.1
 	github.com/mattn/go-sqlite3 v1.14.24
+	golang.org/x/crypto v0.31.0
+	golang.org/x/net v0.33.0
 )
 
-require (
-	github.com/golang-jwt/jwt/v4 v4.5.1 // indirect
-	golang.org/x/crypto v0.31.0 // indirect
-)
+require golang.org/x/text v0.21.0 // indirect
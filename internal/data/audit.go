@@ -0,0 +1,60 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type AuditRepository struct {
+	DB *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{DB: db}
+}
+
+// Insert appends an entry to the audit log
+func (r *AuditRepository) Insert(entry *AuditEntry) (*AuditEntry, error) {
+	query := `INSERT INTO audit_log (user_id, action, ip, user_agent, created_at) 
+	VALUES (?, ?, ?, ?, ?)`
+
+	result, err := r.DB.Exec(query, entry.UserID, entry.Action, entry.IP, entry.UserAgent, entry.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not insert audit entry: %w", err)
+	}
+	entry.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("could not insert audit entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// GetAllForUser retrieves the audit log of a user, oldest entry first
+func (r *AuditRepository) GetAllForUser(userID int64) ([]AuditEntry, error) {
+	query := `SELECT id, user_id, action, ip, user_agent, created_at 
+		FROM audit_log WHERE user_id = ? ORDER BY id`
+
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+
+	for rows.Next() {
+		var entry AuditEntry
+		err := rows.Scan(&entry.ID, &entry.UserID, &entry.Action, &entry.IP, &entry.UserAgent, &entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,88 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+type ClientRepository struct {
+	DB *sql.DB
+}
+
+func NewClientRepository(db *sql.DB) *ClientRepository {
+	return &ClientRepository{DB: db}
+}
+
+// Insert registers a new client
+func (r *ClientRepository) Insert(client *ClientModel) (*ClientModel, error) {
+	query := `INSERT INTO clients (id, name, secret_hash, created_at) 
+	VALUES (?, ?, ?, ?)`
+
+	_, err := r.DB.Exec(query, client.ID, client.Name, client.SecretHash, client.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not insert client: %w", err)
+	}
+
+	return client, nil
+}
+
+// GetByID retrieves a client by its client ID
+func (r *ClientRepository) GetByID(clientID string) (*ClientModel, error) {
+	query := `SELECT id, name, secret_hash, created_at FROM clients WHERE id = ?`
+
+	var client ClientModel
+	err := r.DB.QueryRow(query, clientID).Scan(&client.ID, &client.Name, &client.SecretHash, &client.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("error querying client: %w", err)
+	}
+
+	return &client, nil
+}
+
+func (r *ClientRepository) GetAll() ([]ClientModel, error) {
+	query := `SELECT id, name, secret_hash, created_at FROM clients ORDER BY created_at`
+
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying clients: %w", err)
+	}
+	defer rows.Close()
+
+	var clients []ClientModel
+
+	for rows.Next() {
+		var client ClientModel
+		if err := rows.Scan(&client.ID, &client.Name, &client.SecretHash, &client.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning client: %w", err)
+		}
+		clients = append(clients, client)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return clients, nil
+}
+
+func (r *ClientRepository) Delete(clientID string) error {
+	query := `DELETE FROM clients WHERE id = ?`
+
+	result, err := r.DB.Exec(query, clientID)
+	if err != nil {
+		return fmt.Errorf("could not delete client: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not delete client: %w", err)
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -1,11 +1,37 @@
 package data
 
+import (
+	"errors"
+	"time"
+)
+
+var ErrRecordNotFound = errors.New("record not found")
+
+// ErrEditConflict is returned when a record changed between reading and updating it
+var ErrEditConflict = errors.New("edit conflict")
+
 type UserRepositoryInterface interface {
 	Insert(user *UserModel) (*UserModel, error)
 	GetByEmail(email string) (*UserModel, error)
 	Update(user *UserModel) error
 	GetById(userID int64) (*UserModel, error)
-	UpdateUserActivationStatus(userID int64, status bool) error
+	UpdateUserActivationStatus(userID int64, status bool, version int) error
+	UpdatePassword(userID int64, passwordHash []byte, version int) error
+	SetPendingEmail(userID int64, email string) error
+	ConfirmPendingEmail(userID int64) error
+	RestorePreviousEmail(userID int64) error
+	UpdateProfile(user *UserModel) error
+	GetAll(filter UserFilter) ([]UserModel, int, error)
+	MarkDeleted(userID int64, deletedAt *time.Time, version int) error
+	SetSuspended(userID int64, suspendedAt *time.Time, reason string, version int) error
+	GetAllEmails() ([]UserModel, error)
+	UpdateEmails(userID int64, email, pendingEmail string) error
+	GetDeletedBefore(before time.Time) ([]UserModel, error)
+	Delete(userID int64, version int) error
+	RecordFailedLogin(userID int64, resetBefore time.Time) (int, error)
+	LockUntil(userID int64, until time.Time) error
+	ResetFailedLogins(userID int64) error
+	Unlock(userID int64, version int) error
 }
 
 type TokenRepositoryInterface interface {
@@ -14,6 +40,10 @@ type TokenRepositoryInterface interface {
 	GetByUserID(userID int64) ([]Token, error)
 	GetByUserIDAndScope(userID int64, scope TokenScope) ([]Token, error)
 	DeleteTokensForUser(userID int64, scope TokenScope) error
+	GetByHash(hash []byte) (*Token, error)
+	MarkUsed(hash []byte) (bool, error)
+	DeleteFamily(userID int64, family string) error
+	HashPlaintextTokens() (int64, error)
 }
 
 type PermissionsRepositoryInterface interface {
@@ -22,18 +52,72 @@ type PermissionsRepositoryInterface interface {
 	DeleteUserPermissions(userID, permissionID int64) error
 	GetPermissionIDByName(permission string) (int64, error)
 	GetAllForUser(userID int64) (Permissions, error)
+	InsertRole(role string) error
+	InsertUserRole(userID, roleID int64) error
+	DeleteUserRole(userID, roleID int64) error
+	GetRoleIDByName(role string) (int64, error)
+	GetRolesForUser(userID int64) ([]string, error)
+}
+
+type SigningKeyRepositoryInterface interface {
+	GetAll() ([]SigningKeyModel, error)
+	Rotate(key *SigningKeyModel, retireAt time.Time) error
+	Retire(kid string, retireAt time.Time) error
+	SetPrivateKey(kid string, privateKey []byte) error
+}
+
+type ClientRepositoryInterface interface {
+	Insert(client *ClientModel) (*ClientModel, error)
+	GetByID(clientID string) (*ClientModel, error)
+	GetAll() ([]ClientModel, error)
+	Delete(clientID string) error
 }
+
+type SessionRepositoryInterface interface {
+	Insert(session *Session) (*Session, error)
+	GetAllForUser(userID int64, now time.Time) ([]Session, error)
+	GetByID(userID int64, sessionID string) (*Session, error)
+	Touch(sessionID string, lastSeenAt, writtenBefore time.Time) error
+	Delete(userID int64, sessionID string) error
+	DeleteAllForUser(userID int64) error
+	DeleteDead(now, seenBefore time.Time) (int64, error)
+}
+
+type PersonalAccessTokenRepositoryInterface interface {
+	Insert(token *PersonalAccessToken) (*PersonalAccessToken, error)
+	GetByHash(hash []byte) (*PersonalAccessToken, error)
+	GetAllForUser(userID int64) ([]PersonalAccessToken, error)
+	Touch(tokenID string, lastUsedAt time.Time) error
+	Delete(userID int64, tokenID string) error
+	DeleteAllForUser(userID int64) error
+}
+
+type AuditRepositoryInterface interface {
+	Insert(entry *AuditEntry) (*AuditEntry, error)
+	GetAllForUser(userID int64) ([]AuditEntry, error)
+}
+
 type RepoManager struct {
-	UserRepo        UserRepositoryInterface
-	TokenRepo       TokenRepositoryInterface
-	PermissionsRepo PermissionsRepositoryInterface
+	UserRepo          UserRepositoryInterface
+	TokenRepo         TokenRepositoryInterface
+	PermissionsRepo   PermissionsRepositoryInterface
+	SigningKeyRepo    SigningKeyRepositoryInterface
+	ClientRepo        ClientRepositoryInterface
+	SessionRepo       SessionRepositoryInterface
+	PersonalTokenRepo PersonalAccessTokenRepositoryInterface
+	AuditRepo         AuditRepositoryInterface
 }
 
 // NewRepoManager creates a new instance of RepoManager with the given UserRepository
-func NewRepoManager(userRepo UserRepositoryInterface, tokenRepo TokenRepositoryInterface, permissionRepo PermissionsRepositoryInterface) *RepoManager {
+func NewRepoManager(userRepo UserRepositoryInterface, tokenRepo TokenRepositoryInterface, permissionRepo PermissionsRepositoryInterface, signingKeyRepo SigningKeyRepositoryInterface, clientRepo ClientRepositoryInterface, sessionRepo SessionRepositoryInterface, personalTokenRepo PersonalAccessTokenRepositoryInterface, auditRepo AuditRepositoryInterface) *RepoManager {
 	return &RepoManager{
-		UserRepo:        userRepo,
-		TokenRepo:       tokenRepo,
-		PermissionsRepo: permissionRepo,
+		UserRepo:          userRepo,
+		TokenRepo:         tokenRepo,
+		PermissionsRepo:   permissionRepo,
+		SigningKeyRepo:    signingKeyRepo,
+		ClientRepo:        clientRepo,
+		SessionRepo:       sessionRepo,
+		PersonalTokenRepo: personalTokenRepo,
+		AuditRepo:         auditRepo,
 	}
 }
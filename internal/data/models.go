@@ -11,6 +11,12 @@ type TokenScope string
 const (
 	ActivateEmailToken TokenScope = "ActivateEmailToken"
 	UserAccessToken    TokenScope = "UserAccessToken"
+	RefreshToken       TokenScope = "RefreshToken"
+	PasswordResetToken TokenScope = "PasswordResetToken"
+	// EmailChangeToken confirms a new email address, EmailChangeCancelToken
+	// lets the owner of the old address call the change off
+	EmailChangeToken       TokenScope = "EmailChangeToken"
+	EmailChangeCancelToken TokenScope = "EmailChangeCancelToken"
 )
 
 type Token struct {
@@ -18,6 +24,19 @@ type Token struct {
 	UserID int64      `json:"user_id"`
 	Expiry time.Time  `json:"expiry"`
 	Scope  TokenScope `json:"scope"`
+	Family string     `json:"family"`
+	Used   bool       `json:"used"`
+}
+
+// ----------------
+
+type Session struct {
+	ID         string
+	UserID     int64
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
 }
 
 // ----------------
@@ -30,4 +49,107 @@ type UserModel struct {
 	Password  []byte
 	Activated bool
 	Version   int
+	// PendingEmail is the address the user asked to switch to, empty when no change is pending
+	PendingEmail string
+	// PreviousEmail is the address a confirmed change replaced, kept until the change can no longer be cancelled
+	PreviousEmail string
+	// DeletedAt is set once the user is scheduled for deletion
+	DeletedAt *time.Time
+	// FailedLogins counts the consecutive failed logins, LockedUntil is set while
+	// the account is locked because of them
+	FailedLogins int
+	LockedUntil  *time.Time
+	// SuspendedAt is set while an admin has suspended the user, for SuspensionReason
+	SuspendedAt      *time.Time
+	SuspensionReason string
+	// Status is derived from the columns above, see userStatusColumn
+	Status UserStatus
+	// Optional profile fields, empty when the user has not filled them in
+	GivenName   string
+	FamilyName  string
+	DisplayName string
+	Locale      string
+	TimeZone    string
+}
+
+// UserStatus is the stage of its lifecycle an account is in
+type UserStatus string
+
+const (
+	// UserPending accounts have not verified their email address yet
+	UserPending   UserStatus = "pending"
+	UserActive    UserStatus = "active"
+	UserSuspended UserStatus = "suspended"
+	// UserDeleted accounts are scheduled for deletion and can still be restored
+	UserDeleted UserStatus = "deleted"
+)
+
+// UserStatuses are the values UserFilter.Status accepts
+var UserStatuses = []UserStatus{UserPending, UserActive, UserSuspended, UserDeleted}
+
+// ----------------
+
+type SigningKeyModel struct {
+	ID         string
+	Algorithm  string
+	PrivateKey []byte
+	CreatedAt  time.Time
+	Active     bool
+	RetiredAt  *time.Time
+}
+
+// ----------------
+
+type ClientModel struct {
+	ID         string
+	Name       string
+	SecretHash []byte
+	CreatedAt  time.Time
+}
+
+// ----------------
+
+// PersonalAccessToken is a long-lived token a user creates for scripts and CI jobs.
+// It is limited to Permissions, which is a subset of the user's own permissions.
+type PersonalAccessToken struct {
+	ID          string
+	UserID      int64
+	Name        string
+	Hash        []byte
+	Permissions Permissions
+	CreatedAt   time.Time
+	ExpiresAt   *time.Time
+	LastUsedAt  *time.Time
+}
+
+// ----------------
+
+// UserFilter selects and orders a page of users. Zero values leave a criterion out.
+type UserFilter struct {
+	Activated     *bool
+	Status        UserStatus
+	Email         string
+	Name          string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Permission    string
+	// Sort is a column name, prefixed with "-" for descending order
+	Sort     string
+	Page     int
+	PageSize int
+}
+
+// UserSortColumns are the values UserFilter.Sort accepts, without the "-" prefix
+var UserSortColumns = []string{"id", "name", "email", "created_at"}
+
+// ----------------
+
+// AuditEntry records a security relevant action taken on a user's account
+type AuditEntry struct {
+	ID        int64
+	UserID    int64
+	Action    string
+	IP        string
+	UserAgent string
+	CreatedAt time.Time
 }
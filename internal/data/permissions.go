@@ -95,3 +95,80 @@ func (m *PermissionsRepository) GetAllForUser(userID int64) (Permissions, error)
 
 	return permissions, nil
 }
+
+func (m *PermissionsRepository) InsertRole(role string) error {
+	stmt := `INSERT INTO roles (role) VALUES ($1)`
+	_, err := m.DB.Exec(stmt, role)
+	if err != nil {
+		log.Printf("Error inserting role: %v", err)
+		return fmt.Errorf("could not insert role: %w", err)
+	}
+	return nil
+}
+
+func (m *PermissionsRepository) InsertUserRole(userID, roleID int64) error {
+	stmt := `INSERT INTO users_roles (user_id, role_id) VALUES ($1, $2)`
+	_, err := m.DB.Exec(stmt, userID, roleID)
+	if err != nil {
+		log.Printf("Error inserting user role: %v", err)
+		return fmt.Errorf("could not insert user role: %w", err)
+	}
+	return nil
+}
+
+func (m *PermissionsRepository) DeleteUserRole(userID, roleID int64) error {
+	stmt := `DELETE FROM users_roles WHERE user_id = $1 AND role_id = $2`
+	_, err := m.DB.Exec(stmt, userID, roleID)
+	if err != nil {
+		log.Printf("Error deleting user role: %v", err)
+		return fmt.Errorf("could not delete user role: %w", err)
+	}
+	return nil
+}
+
+func (m *PermissionsRepository) GetRoleIDByName(role string) (int64, error) {
+	query := `SELECT id FROM roles WHERE role = $1 LIMIT 1`
+
+	var roleID int64
+	err := m.DB.QueryRow(query, role).Scan(&roleID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("role not found: %w", err)
+		}
+		return 0, fmt.Errorf("could not retrieve role ID: %w", err)
+	}
+
+	return roleID, nil
+}
+
+func (m *PermissionsRepository) GetRolesForUser(userID int64) ([]string, error) {
+	query := `
+        SELECT roles.role
+        FROM roles
+        INNER JOIN users_roles ON users_roles.role_id = roles.id
+        WHERE users_roles.user_id = $1`
+
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []string
+
+	for rows.Next() {
+		var role string
+
+		err := rows.Scan(&role)
+		if err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, role)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
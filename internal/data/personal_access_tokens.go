@@ -0,0 +1,144 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type PersonalAccessTokenRepository struct {
+	DB *sql.DB
+}
+
+func NewPersonalAccessTokenRepository(db *sql.DB) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{DB: db}
+}
+
+// Insert stores a new personal access token, its permissions are kept as a space separated list
+func (r *PersonalAccessTokenRepository) Insert(token *PersonalAccessToken) (*PersonalAccessToken, error) {
+	query := `INSERT INTO personal_access_tokens (id, user_id, name, hash, permissions, created_at, expires_at) 
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.DB.Exec(query, token.ID, token.UserID, token.Name, token.Hash,
+		strings.Join(token.Permissions, " "), token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not insert personal access token: %w", err)
+	}
+
+	return token, nil
+}
+
+// GetByHash retrieves a personal access token by the hash of its plaintext
+func (r *PersonalAccessTokenRepository) GetByHash(hash []byte) (*PersonalAccessToken, error) {
+	query := `SELECT id, user_id, name, hash, permissions, created_at, expires_at, last_used_at 
+		FROM personal_access_tokens WHERE hash = ?`
+
+	token, err := scanPersonalAccessToken(r.DB.QueryRow(query, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("error querying personal access token: %w", err)
+	}
+
+	return token, nil
+}
+
+// GetAllForUser retrieves the personal access tokens of a user, newest first
+func (r *PersonalAccessTokenRepository) GetAllForUser(userID int64) ([]PersonalAccessToken, error) {
+	query := `SELECT id, user_id, name, hash, permissions, created_at, expires_at, last_used_at 
+		FROM personal_access_tokens WHERE user_id = ? ORDER BY created_at DESC`
+
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying personal access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []PersonalAccessToken
+
+	for rows.Next() {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning personal access token: %w", err)
+		}
+		tokens = append(tokens, *token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// Touch records that a personal access token was just used
+func (r *PersonalAccessTokenRepository) Touch(tokenID string, lastUsedAt time.Time) error {
+	query := `UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ?`
+
+	_, err := r.DB.Exec(query, lastUsedAt, tokenID)
+	if err != nil {
+		return fmt.Errorf("could not update personal access token: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a personal access token of the given user
+func (r *PersonalAccessTokenRepository) Delete(userID int64, tokenID string) error {
+	query := `DELETE FROM personal_access_tokens WHERE id = ? AND user_id = ?`
+
+	result, err := r.DB.Exec(query, tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("could not delete personal access token: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not delete personal access token: %w", err)
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (r *PersonalAccessTokenRepository) DeleteAllForUser(userID int64) error {
+	query := `DELETE FROM personal_access_tokens WHERE user_id = ?`
+
+	_, err := r.DB.Exec(query, userID)
+	if err != nil {
+		return fmt.Errorf("could not delete personal access tokens: %w", err)
+	}
+
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPersonalAccessToken(row rowScanner) (*PersonalAccessToken, error) {
+	var token PersonalAccessToken
+	var permissions string
+	var expiresAt, lastUsedAt sql.NullTime
+
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Hash, &permissions,
+		&token.CreatedAt, &expiresAt, &lastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Permissions = strings.Fields(permissions)
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{DB: db}
+}
+
+// Insert records a new login session
+func (r *SessionRepository) Insert(session *Session) (*Session, error) {
+	query := `INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_seen_at) 
+	VALUES (?, ?, ?, ?, ?, ?)`
+
+	_, err := r.DB.Exec(query, session.ID, session.UserID, session.UserAgent, session.IP, session.CreatedAt, session.LastSeenAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not insert session: %w", err)
+	}
+
+	return session, nil
+}
+
+// liveSession matches sessions that still have an unused, unexpired refresh token, the rest
+// can no longer be continued. It takes the current time as its only parameter.
+const liveSession = `EXISTS (SELECT 1 FROM tokens WHERE tokens.family = sessions.id
+	AND tokens.scope = '` + string(RefreshToken) + `' AND tokens.used = 0 AND julianday(tokens.expiry) > julianday(?))`
+
+// GetAllForUser retrieves the live sessions of a user, most recently used first
+func (r *SessionRepository) GetAllForUser(userID int64, now time.Time) ([]Session, error) {
+	query := `SELECT id, user_id, user_agent, ip, created_at, last_seen_at 
+		FROM sessions WHERE user_id = ? AND ` + liveSession + ` ORDER BY last_seen_at DESC`
+
+	rows, err := r.DB.Query(query, userID, now)
+	if err != nil {
+		return nil, fmt.Errorf("error querying sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []Session
+
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// GetByID retrieves a session of the given user
+func (r *SessionRepository) GetByID(userID int64, sessionID string) (*Session, error) {
+	query := `SELECT id, user_id, user_agent, ip, created_at, last_seen_at 
+		FROM sessions WHERE id = ? AND user_id = ?`
+
+	var session Session
+	err := r.DB.QueryRow(query, sessionID, userID).Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("error querying session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// Touch records that a session was just used, unless that was already written after writtenBefore
+func (r *SessionRepository) Touch(sessionID string, lastSeenAt, writtenBefore time.Time) error {
+	query := `UPDATE sessions SET last_seen_at = ? WHERE id = ? AND julianday(last_seen_at) < julianday(?)`
+
+	_, err := r.DB.Exec(query, lastSeenAt, sessionID, writtenBefore)
+	if err != nil {
+		return fmt.Errorf("could not update session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SessionRepository) Delete(userID int64, sessionID string) error {
+	query := `DELETE FROM sessions WHERE id = ? AND user_id = ?`
+
+	_, err := r.DB.Exec(query, sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("could not delete session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SessionRepository) DeleteAllForUser(userID int64) error {
+	query := `DELETE FROM sessions WHERE user_id = ?`
+
+	_, err := r.DB.Exec(query, userID)
+	if err != nil {
+		return fmt.Errorf("could not delete sessions: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteDead removes the sessions without a live refresh token and returns how many there were.
+// Sessions seen since seenBefore are kept, a login stores its session before its tokens.
+func (r *SessionRepository) DeleteDead(now, seenBefore time.Time) (int64, error) {
+	query := `DELETE FROM sessions WHERE julianday(last_seen_at) < julianday(?) AND NOT ` + liveSession
+
+	result, err := r.DB.Exec(query, seenBefore, now)
+	if err != nil {
+		return 0, fmt.Errorf("could not delete sessions: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,110 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type SigningKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewSigningKeyRepository(db *sql.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{DB: db}
+}
+
+// GetAll retrieves every signing key, including retired ones
+func (r *SigningKeyRepository) GetAll() ([]SigningKeyModel, error) {
+	query := `SELECT kid, algorithm, private_key, created_at, active, retired_at
+		FROM signing_keys ORDER BY created_at`
+
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []SigningKeyModel
+
+	for rows.Next() {
+		var key SigningKeyModel
+		var retiredAt sql.NullTime
+		err := rows.Scan(&key.ID, &key.Algorithm, &key.PrivateKey, &key.CreatedAt, &key.Active, &retiredAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning signing key: %w", err)
+		}
+		if retiredAt.Valid {
+			key.RetiredAt = &retiredAt.Time
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Rotate makes the given key the active signing key. The previously active key
+// stays valid for verification until retireAt.
+func (r *SigningKeyRepository) Rotate(key *SigningKeyModel, retireAt time.Time) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("could not start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE signing_keys SET active = 0, retired_at = ? WHERE active = 1`, retireAt)
+	if err != nil {
+		return fmt.Errorf("could not deactivate signing key: %w", err)
+	}
+
+	query := `INSERT INTO signing_keys (kid, algorithm, private_key, created_at, active) 
+	VALUES (?, ?, ?, ?, 1)`
+	_, err = tx.Exec(query, key.ID, key.Algorithm, key.PrivateKey, key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("could not insert signing key: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Retire stops a non-active key from being accepted for verification at the given time
+func (r *SigningKeyRepository) Retire(kid string, retireAt time.Time) error {
+	query := `UPDATE signing_keys SET retired_at = ? WHERE kid = ? AND active = 0`
+
+	result, err := r.DB.Exec(query, retireAt, kid)
+	if err != nil {
+		return fmt.Errorf("could not retire signing key: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not retire signing key: %w", err)
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// SetPrivateKey replaces the stored key material of a signing key, e.g. once it has been encrypted
+func (r *SigningKeyRepository) SetPrivateKey(kid string, privateKey []byte) error {
+	query := `UPDATE signing_keys SET private_key = ? WHERE kid = ?`
+
+	result, err := r.DB.Exec(query, privateKey, kid)
+	if err != nil {
+		return fmt.Errorf("could not update signing key: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not update signing key: %w", err)
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
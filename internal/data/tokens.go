@@ -2,13 +2,19 @@ package data
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
 	"time"
 )
 
+// timestampLayout is the layout used by the sqlite3 driver when storing time.Time values
+// in columns it does not recognise as timestamps, such as tokens.expiry.
+const timestampLayout = "2006-01-02 15:04:05.999999-07:00"
+
 func GenerateHashToken() ([]byte, error) {
 	token, err := GenerateRandomToken()
 	if err != nil {
@@ -21,6 +27,13 @@ func GenerateHashToken() ([]byte, error) {
 	return hash, nil
 }
 
+// HashToken returns the SHA-256 digest of a token. Only the digest is ever stored,
+// so a copy of the tokens table cannot be replayed as bearer tokens.
+func HashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
 func GenerateRandomToken() (string, error) {
 	tokenBytes := make([]byte, 16)
 
@@ -44,11 +57,11 @@ func NewTokenRepository(db *sql.DB) *TokenRepository {
 // Insert inserts a new token into the database
 func (r *TokenRepository) Insert(token *Token) (*Token, error) {
 	// Prepare the SQL query to insert a new token
-	query := `INSERT INTO tokens (hash, user_id, expiry, scope) 
-	VALUES (?, ?, ?, ?)`
+	query := `INSERT INTO tokens (hash, user_id, expiry, scope, family, used) 
+	VALUES (?, ?, ?, ?, ?, ?)`
 
 	// Execute the query
-	_, err := r.DB.Exec(query, token.Hash, token.UserID, token.Expiry, string(token.Scope))
+	_, err := r.DB.Exec(query, token.Hash, token.UserID, token.Expiry, string(token.Scope), token.Family, token.Used)
 	if err != nil {
 		return nil, fmt.Errorf("could not insert token: %w", err)
 	}
@@ -105,7 +118,7 @@ func (repo *TokenRepository) GetByUserID(userID int64) ([]Token, error) {
 }
 
 func (repo *TokenRepository) GetByUserIDAndScope(userID int64, scope TokenScope) ([]Token, error) {
-	query := `SELECT hash, user_id, expiry, scope FROM tokens WHERE user_id = ? and scope = ?`
+	query := `SELECT hash, user_id, expiry, scope, used FROM tokens WHERE user_id = ? and scope = ?`
 
 	rows, err := repo.DB.Query(query, userID, string(scope))
 	if err != nil {
@@ -118,10 +131,10 @@ func (repo *TokenRepository) GetByUserIDAndScope(userID int64, scope TokenScope)
 
 	for rows.Next() {
 		var token Token
-		if err := rows.Scan(&token.Hash, &token.UserID, &expiry, &token.Scope); err != nil {
+		if err := rows.Scan(&token.Hash, &token.UserID, &expiry, &token.Scope, &token.Used); err != nil {
 			return nil, fmt.Errorf("error scanning token: %w", err)
 		}
-		parsedExpiry, err := time.Parse("2006-01-02 15:04:05.999999-07:00", expiry)
+		parsedExpiry, err := time.Parse(timestampLayout, expiry)
 		if err != nil {
 			return nil, fmt.Errorf("error parsing expiry: %w", err)
 		}
@@ -139,9 +152,102 @@ func (repo *TokenRepository) GetByUserIDAndScope(userID int64, scope TokenScope)
 	return tokens, nil
 }
 
+// GetByHash retrieves a single token by its hash
+func (repo *TokenRepository) GetByHash(hash []byte) (*Token, error) {
+	query := `SELECT hash, user_id, expiry, scope, family, used FROM tokens WHERE hash = ?`
+
+	var token Token
+	var expiry string
+
+	err := repo.DB.QueryRow(query, hash).Scan(&token.Hash, &token.UserID, &expiry, &token.Scope, &token.Family, &token.Used)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("error querying token: %w", err)
+	}
+	token.Expiry, err = time.Parse(timestampLayout, expiry)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing expiry: %w", err)
+	}
+
+	return &token, nil
+}
+
+// MarkUsed flags a token as used. It reports false when the token was already used,
+// so two concurrent callers can never both consume the same token.
+func (r *TokenRepository) MarkUsed(hash []byte) (bool, error) {
+	query := `UPDATE tokens SET used = 1 WHERE hash = ? AND used = 0`
+
+	result, err := r.DB.Exec(query, hash)
+	if err != nil {
+		return false, fmt.Errorf("could not mark token as used: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not mark token as used: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// DeleteFamily deletes every token issued for the same login of a user
+func (r *TokenRepository) DeleteFamily(userID int64, family string) error {
+	query := `DELETE FROM tokens WHERE user_id = ? and family = ?`
+
+	_, err := r.DB.Exec(query, userID, family)
+	if err != nil {
+		return fmt.Errorf("could not delete token family: %w", err)
+	}
+
+	return nil
+}
+
+// HashPlaintextTokens replaces tokens that were stored in plain text by their SHA-256 digest.
+// Digests are always sha256.Size bytes long, so rows that were already hashed are skipped
+// and running it again does no harm.
+func (r *TokenRepository) HashPlaintextTokens() (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("could not start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// plain text tokens were stored as text, which never equals a blob parameter, so rows
+	// are updated by rowid
+	rows, err := tx.Query(`SELECT rowid, hash FROM tokens WHERE length(hash) <> ?`, sha256.Size)
+	if err != nil {
+		return 0, fmt.Errorf("error querying tokens: %w", err)
+	}
+
+	plaintexts := make(map[int64][]byte)
+	for rows.Next() {
+		var rowID int64
+		var plaintext []byte
+		if err := rows.Scan(&rowID, &plaintext); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning token: %w", err)
+		}
+		plaintexts[rowID] = plaintext
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	for rowID, plaintext := range plaintexts {
+		_, err = tx.Exec(`UPDATE tokens SET hash = ? WHERE rowid = ?`, HashToken(string(plaintext)), rowID)
+		if err != nil {
+			return 0, fmt.Errorf("could not hash token: %w", err)
+		}
+	}
+
+	return int64(len(plaintexts)), tx.Commit()
+}
+
 func isValidTokenScope(scope string) bool {
 	switch TokenScope(scope) {
-	case ActivateEmailToken, UserAccessToken:
+	case ActivateEmailToken, UserAccessToken, RefreshToken, PasswordResetToken, EmailChangeToken, EmailChangeCancelToken:
 		return true
 	default:
 		return false
@@ -3,6 +3,9 @@ package data
 import (
 	"database/sql"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 )
 
 // UserRepository struct holds a reference to the database connection
@@ -10,6 +13,15 @@ type UserRepository struct {
 	DB *sql.DB
 }
 
+// userStatusColumn works out a user's UserStatus. Deletion takes precedence over suspension,
+// which takes precedence over whether the email address has been verified.
+const userStatusColumn = `CASE
+		WHEN deleted_at IS NOT NULL THEN 'deleted'
+		WHEN suspended_at IS NOT NULL THEN 'suspended'
+		WHEN activated THEN 'active'
+		ELSE 'pending'
+	END`
+
 // NewUserRepository creates a new UserRepository with the given DB connection
 func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{DB: db}
@@ -35,50 +47,429 @@ func (r *UserRepository) Insert(user *UserModel) (*UserModel, error) {
 
 // GetByEmail retrieves a user by email from the database
 func (r *UserRepository) GetByEmail(email string) (*UserModel, error) {
-	query := `SELECT id, name, email, password_hash, activated, version, created_at 
-		FROM users WHERE email = ?`
+	query := `SELECT id, name, email, password_hash, activated, version, created_at, pending_email, deleted_at, 
+		failed_logins, locked_until, suspended_at, suspension_reason, ` + userStatusColumn + `, 
+		given_name, family_name, display_name, locale, time_zone, previous_email FROM users WHERE email = ?`
 	row := r.DB.QueryRow(query, email)
 
 	var user UserModel
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Activated, &user.Version, &user.CreatedAt)
+	var deletedAt, lockedUntil, suspendedAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Activated, &user.Version, &user.CreatedAt, &user.PendingEmail, &deletedAt,
+		&user.FailedLogins, &lockedUntil, &suspendedAt, &user.SuspensionReason, &user.Status,
+		&user.GivenName, &user.FamilyName, &user.DisplayName, &user.Locale, &user.TimeZone, &user.PreviousEmail)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("user with email %s not found", email)
 		}
 		return nil, err
 	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	if lockedUntil.Valid {
+		user.LockedUntil = &lockedUntil.Time
+	}
+	if suspendedAt.Valid {
+		user.SuspendedAt = &suspendedAt.Time
+	}
 
 	return &user, nil
 }
 func (r *UserRepository) GetById(userID int64) (*UserModel, error) {
-	query := `SELECT id, name, email, password_hash, activated, version, created_at 
-		FROM users WHERE id = ?`
+	query := `SELECT id, name, email, password_hash, activated, version, created_at, pending_email, deleted_at, 
+		failed_logins, locked_until, suspended_at, suspension_reason, ` + userStatusColumn + `, 
+		given_name, family_name, display_name, locale, time_zone, previous_email FROM users WHERE id = ?`
 	row := r.DB.QueryRow(query, userID)
 
 	var user UserModel
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Activated, &user.Version, &user.CreatedAt)
+	var deletedAt, lockedUntil, suspendedAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Activated, &user.Version, &user.CreatedAt, &user.PendingEmail, &deletedAt,
+		&user.FailedLogins, &lockedUntil, &suspendedAt, &user.SuspensionReason, &user.Status,
+		&user.GivenName, &user.FamilyName, &user.DisplayName, &user.Locale, &user.TimeZone, &user.PreviousEmail)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("user with id %d not found", userID)
 		}
 		return nil, err
 	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	if lockedUntil.Valid {
+		user.LockedUntil = &lockedUntil.Time
+	}
+	if suspendedAt.Valid {
+		user.SuspendedAt = &suspendedAt.Time
+	}
 
 	return &user, nil
 }
 
-// Update updates an existing user's details in the database
+// Update updates an existing user's details in the database. It only succeeds while the
+// stored version still matches user.Version and returns ErrEditConflict otherwise.
 func (r *UserRepository) Update(user *UserModel) error {
 
 	query := `UPDATE users SET name = ?, email = ?, password_hash = ?,  version = version + 1  , activated=?
-		WHERE id = ?`
-	_, err := r.DB.Exec(query, user.Name, user.Email, user.Password, user.Activated, user.ID)
+		WHERE id = ? AND version = ?`
+	result, err := r.DB.Exec(query, user.Name, user.Email, user.Password, user.Activated, user.ID, user.Version)
+	if err != nil {
+		return err
+	}
+	return checkVersionedUpdate(result)
+}
+
+func (r *UserRepository) UpdateUserActivationStatus(userID int64, status bool, version int) error {
+
+	query := `UPDATE users SET  version = version + 1  , activated=? WHERE id = ? AND version = ?`
+	result, err := r.DB.Exec(query, status, userID, version)
+	if err != nil {
+		return err
+	}
+	return checkVersionedUpdate(result)
+}
+
+// UpdatePassword replaces the password hash of a user, as long as the stored version still matches
+func (r *UserRepository) UpdatePassword(userID int64, passwordHash []byte, version int) error {
+
+	query := `UPDATE users SET password_hash = ?, version = version + 1 WHERE id = ? AND version = ?`
+	result, err := r.DB.Exec(query, passwordHash, userID, version)
+	if err != nil {
+		return err
+	}
+	return checkVersionedUpdate(result)
+}
+
+// SetPendingEmail records the address a user wants to switch to, an empty email clears it.
+// Either way the address kept from an earlier change is forgotten.
+func (r *UserRepository) SetPendingEmail(userID int64, email string) error {
+
+	query := `UPDATE users SET pending_email = ?, previous_email = '' WHERE id = ?`
+	_, err := r.DB.Exec(query, email, userID)
+	return err
+}
+
+// ConfirmPendingEmail makes the pending email address the user's email and keeps the old one
+// as the previous email, so the change can still be undone. The new address has just been
+// verified, so the account counts as activated.
+func (r *UserRepository) ConfirmPendingEmail(userID int64) error {
+
+	query := `UPDATE users SET previous_email = email, email = pending_email, pending_email = '', activated = 1,
+		version = version + 1 WHERE id = ? AND pending_email <> ''`
+	_, err := r.DB.Exec(query, userID)
+	return err
+}
+
+// RestorePreviousEmail undoes a confirmed email change
+func (r *UserRepository) RestorePreviousEmail(userID int64) error {
+
+	query := `UPDATE users SET email = previous_email, previous_email = '', version = version + 1
+		WHERE id = ? AND previous_email <> ''`
+	_, err := r.DB.Exec(query, userID)
+	return err
+}
+
+// UpdateProfile stores the profile fields a user may edit themselves. Like Update it
+// returns ErrEditConflict when the stored version no longer matches user.Version.
+func (r *UserRepository) UpdateProfile(user *UserModel) error {
+
+	query := `UPDATE users SET name = ?, given_name = ?, family_name = ?, display_name = ?, locale = ?, time_zone = ?,
+		version = version + 1 WHERE id = ? AND version = ?`
+	result, err := r.DB.Exec(query, user.Name, user.GivenName, user.FamilyName, user.DisplayName, user.Locale, user.TimeZone,
+		user.ID, user.Version)
+	if err != nil {
+		return err
+	}
+	return checkVersionedUpdate(result)
+}
+
+// RecordFailedLogin counts a failed login and returns the number of consecutive failures.
+// The count starts over when the previous failure, or the lockout that followed it, ended
+// before resetBefore. Lockout state is not part of the profile, so the version is left alone.
+func (r *UserRepository) RecordFailedLogin(userID int64, resetBefore time.Time) (int, error) {
+
+	query := `UPDATE users SET
+			failed_logins = CASE
+				WHEN last_failed_login IS NULL
+					OR max(julianday(last_failed_login), coalesce(julianday(locked_until), 0)) < julianday(?)
+				THEN 1
+				ELSE failed_logins + 1
+			END,
+			last_failed_login = ?
+		WHERE id = ?
+		RETURNING failed_logins`
+
+	var failures int
+	err := r.DB.QueryRow(query, resetBefore, time.Now(), userID).Scan(&failures)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrRecordNotFound
+		}
+		return 0, err
+	}
+	return failures, nil
+}
+
+// LockUntil locks a user out of logging in until the given time
+func (r *UserRepository) LockUntil(userID int64, until time.Time) error {
+
+	query := `UPDATE users SET locked_until = ? WHERE id = ?`
+	_, err := r.DB.Exec(query, until, userID)
 	return err
 }
 
-func (r *UserRepository) UpdateUserActivationStatus(userID int64, status bool) error {
+// Unlock lifts a lockout on behalf of an administrator. Like ResetFailedLogins it leaves the
+// version alone, but it only succeeds while the stored version still matches.
+func (r *UserRepository) Unlock(userID int64, version int) error {
+
+	query := `UPDATE users SET failed_logins = 0, last_failed_login = NULL, locked_until = NULL
+		WHERE id = ? AND version = ?`
+	result, err := r.DB.Exec(query, userID, version)
+	if err != nil {
+		return err
+	}
+	return checkVersionedUpdate(result)
+}
+
+// ResetFailedLogins clears the failed login count and lifts any lockout
+func (r *UserRepository) ResetFailedLogins(userID int64) error {
+
+	query := `UPDATE users SET failed_logins = 0, last_failed_login = NULL, locked_until = NULL WHERE id = ?`
+	result, err := r.DB.Exec(query, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func checkVersionedUpdate(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+// GetAll retrieves one page of the users matching the filter, together with the total number of matches
+func (r *UserRepository) GetAll(filter UserFilter) ([]UserModel, int, error) {
+	var conditions []string
+	var args []any
+
+	if filter.Activated != nil {
+		conditions = append(conditions, "activated = ?")
+		args = append(args, *filter.Activated)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, userStatusColumn+" = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.Email != "" {
+		conditions = append(conditions, "instr(lower(email), lower(?)) > 0")
+		args = append(args, filter.Email)
+	}
+	if filter.Name != "" {
+		conditions = append(conditions, "instr(lower(name), lower(?)) > 0")
+		args = append(args, filter.Name)
+	}
+	if filter.CreatedAfter != nil {
+		conditions = append(conditions, "julianday(created_at) >= julianday(?)")
+		args = append(args, *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, "julianday(created_at) < julianday(?)")
+		args = append(args, *filter.CreatedBefore)
+	}
+	if filter.Permission != "" {
+		conditions = append(conditions, `id IN (
+			SELECT users_permissions.user_id FROM users_permissions
+			INNER JOIN permissions ON permissions.id = users_permissions.permission_id
+			WHERE permissions.permission = ?)`)
+		args = append(args, filter.Permission)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// the sort column is checked against UserSortColumns, it is never taken from the request as is
+	column, direction := strings.TrimPrefix(filter.Sort, "-"), "ASC"
+	if !slices.Contains(UserSortColumns, column) {
+		column = "id"
+	}
+	if strings.HasPrefix(filter.Sort, "-") {
+		direction = "DESC"
+	}
+
+	// counted on its own, so a page past the last one still reports the total
+	total := 0
+	err := r.DB.QueryRow(`SELECT count(*) FROM users `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting users: %w", err)
+	}
+
+	query := fmt.Sprintf(`SELECT id, name, email, password_hash, activated, version, created_at, pending_email, deleted_at, 
+		suspended_at, suspension_reason, %s FROM users %s ORDER BY %s %s, id ASC LIMIT ? OFFSET ?`, userStatusColumn, where, column, direction)
+	args = append(args, filter.PageSize, (filter.Page-1)*filter.PageSize)
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []UserModel
+
+	for rows.Next() {
+		var user UserModel
+		var deletedAt, suspendedAt sql.NullTime
+		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Activated, &user.Version, &user.CreatedAt, &user.PendingEmail, &deletedAt,
+			&suspendedAt, &user.SuspensionReason, &user.Status)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error scanning user: %w", err)
+		}
+		if deletedAt.Valid {
+			user.DeletedAt = &deletedAt.Time
+		}
+		if suspendedAt.Valid {
+			user.SuspendedAt = &suspendedAt.Time
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return users, total, nil
+}
+
+// MarkDeleted schedules a user for deletion, or cancels that when deletedAt is nil. It
+// returns ErrEditConflict when the stored version no longer matches.
+func (r *UserRepository) MarkDeleted(userID int64, deletedAt *time.Time, version int) error {
+
+	query := `UPDATE users SET deleted_at = ?, version = version + 1 WHERE id = ? AND version = ?`
+	result, err := r.DB.Exec(query, deletedAt, userID, version)
+	if err != nil {
+		return err
+	}
+	return checkVersionedUpdate(result)
+}
+
+// GetAllEmails returns the ID, email and pending email address of every user, ordered by ID
+func (r *UserRepository) GetAllEmails() ([]UserModel, error) {
+	query := `SELECT id, email, pending_email FROM users ORDER BY id`
+
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying user emails: %w", err)
+	}
+	defer rows.Close()
+
+	var users []UserModel
+	for rows.Next() {
+		var user UserModel
+		if err := rows.Scan(&user.ID, &user.Email, &user.PendingEmail); err != nil {
+			return nil, fmt.Errorf("error scanning user email: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return users, nil
+}
+
+// UpdateEmails replaces the email and pending email address of a user as is, without any verification
+func (r *UserRepository) UpdateEmails(userID int64, email, pendingEmail string) error {
 
-	query := `UPDATE users SET  version = version + 1  , activated=? WHERE id = ?`
-	_, err := r.DB.Exec(query, status, userID)
+	query := `UPDATE users SET email = ?, pending_email = ? WHERE id = ?`
+	_, err := r.DB.Exec(query, email, pendingEmail, userID)
 	return err
 }
+
+// SetSuspended suspends a user for the given reason, or reinstates them when suspendedAt is nil.
+// It returns ErrEditConflict when the stored version no longer matches.
+func (r *UserRepository) SetSuspended(userID int64, suspendedAt *time.Time, reason string, version int) error {
+
+	query := `UPDATE users SET suspended_at = ?, suspension_reason = ?, version = version + 1
+		WHERE id = ? AND version = ?`
+	result, err := r.DB.Exec(query, suspendedAt, reason, userID, version)
+	if err != nil {
+		return err
+	}
+	return checkVersionedUpdate(result)
+}
+
+// GetDeletedBefore returns the ID and version of users scheduled for deletion before the given time
+func (r *UserRepository) GetDeletedBefore(before time.Time) ([]UserModel, error) {
+	query := `SELECT id, version FROM users WHERE deleted_at IS NOT NULL AND julianday(deleted_at) <= julianday(?)`
+
+	rows, err := r.DB.Query(query, before)
+	if err != nil {
+		return nil, fmt.Errorf("error querying deleted users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []UserModel
+	for rows.Next() {
+		var user UserModel
+		if err := rows.Scan(&user.ID, &user.Version); err != nil {
+			return nil, fmt.Errorf("error scanning user id: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return users, nil
+}
+
+// Delete permanently removes a user and everything stored about them except the audit log,
+// whose entries are anonymised. SQLite does not enforce the foreign keys by default, so the
+// dependent rows are removed explicitly. It returns ErrEditConflict, and removes nothing, when the
+// user is no longer at the given version.
+func (r *UserRepository) Delete(userID int64, version int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM users WHERE id = ? AND version = ?`, userID, version)
+	if err != nil {
+		return fmt.Errorf("could not delete user: %w", err)
+	}
+	err = checkVersionedUpdate(result)
+	if err != nil {
+		return err
+	}
+
+	statements := []string{
+		`DELETE FROM tokens WHERE user_id = ?`,
+		`DELETE FROM sessions WHERE user_id = ?`,
+		`DELETE FROM personal_access_tokens WHERE user_id = ?`,
+		`DELETE FROM users_permissions WHERE user_id = ?`,
+		`DELETE FROM users_roles WHERE user_id = ?`,
+		// the audit log is kept, without the address and browser it was recorded from
+		`UPDATE audit_log SET ip = '', user_agent = '' WHERE user_id = ?`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, userID); err != nil {
+			return fmt.Errorf("could not delete user: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
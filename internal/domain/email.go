@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"errors"
+	"golang.org/x/net/idna"
+	"strings"
+)
+
+// ErrInvalidEmailDomain is returned for addresses whose domain is not a valid, IDNA conformant domain name
+var ErrInvalidEmailDomain = errors.New("email domain is not a valid domain name")
+
+// NormalizeEmail brings an email address into the form it is stored and looked up in. It trims
+// surrounding white space and maps the domain to its lowercase ASCII form with the IDNA lookup
+// profile (UTS #46), so internationalized domain names are stored as punycode. The local part is
+// only lowercased with foldLocalPart, RFC 5321 leaves its case significant even though hardly any
+// mail server treats it that way. Domains IDNA refuses yield ErrInvalidEmailDomain together with
+// the trimmed address.
+func NormalizeEmail(address string, foldLocalPart bool) (string, error) {
+	address = strings.TrimSpace(address)
+
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return address, nil
+	}
+	local, domain := address[:at], address[at+1:]
+	if foldLocalPart {
+		local = strings.ToLower(local)
+	}
+
+	domain, err := idna.Lookup.ToASCII(domain)
+	if err != nil || domain == "" {
+		return address, ErrInvalidEmailDomain
+	}
+
+	return local + "@" + domain, nil
+}
@@ -1,10 +1,17 @@
 package domain
 
+import "fmt"
+
 type OperationErrors struct {
 	Validation map[string][]string
 	Database   map[string][]string
 }
 
+// Error lets OperationErrors be returned where an error is expected
+func (ve *OperationErrors) Error() string {
+	return fmt.Sprintf("validation: %v, database: %v", ve.Validation, ve.Database)
+}
+
 func (ve *OperationErrors) AddValidationError(field, message string) {
 	if ve.Validation == nil {
 		ve.Validation = make(map[string][]string)
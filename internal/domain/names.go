@@ -0,0 +1,216 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxNameLength = 100
+
+// Errors reported by checkName, each is prefixed with the name of the field
+const (
+	errNameInvalidCharacters = "contains invalid characters"
+	errNameMixedScripts      = "mixes letters from different scripts"
+)
+
+// scriptCombinations are the mixes of scripts that are written together, a name using letters
+// from any other mix of scripts is rejected as it is likely made up of look-alike characters
+var scriptCombinations = [][]string{
+	{"Han", "Hiragana", "Katakana"},
+	{"Han", "Hangul"},
+	{"Han", "Bopomofo"},
+}
+
+// checkName validates a personal name and returns a description of the first problem found, or
+// an empty string for a valid name. Names are made of Unicode letters with their combining marks,
+// separated by single spaces, hyphens, apostrophes, full stops or katakana middle dots. A full
+// stop may also end an abbreviation, as in "J. Smith" or "Jr.". Control characters, digits and
+// symbols are rejected, as are names mixing scripts that are not written together.
+func checkName(name string) string {
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return fmt.Sprintf("length must be between 1 and %d characters", maxNameLength)
+	}
+
+	scripts := make(map[string]bool)
+	var previous rune
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r):
+			if script := scriptOf(r); script != "" {
+				scripts[script] = true
+			}
+		case unicode.IsMark(r), r == '\u200c', r == '\u200d':
+			// combining marks and the zero width (non-)joiner only make sense after a letter
+			if i == 0 || !(unicode.IsLetter(previous) || unicode.IsMark(previous)) {
+				return errNameInvalidCharacters
+			}
+		case isNameSeparator(r):
+			// after an abbreviation only a space may follow, "J. Smith" but not "J.-Smith"
+			if i == 0 || (isNameSeparator(previous) && !(previous == '.' && r == ' ')) {
+				return errNameInvalidCharacters
+			}
+		default:
+			return errNameInvalidCharacters
+		}
+		previous = r
+	}
+	if isNameSeparator(previous) && previous != '.' {
+		return errNameInvalidCharacters
+	}
+
+	if len(scripts) > 1 && !isScriptCombination(scripts) {
+		return errNameMixedScripts
+	}
+	return ""
+}
+
+// isNameSeparator reports whether r may stand between the parts of a name
+func isNameSeparator(r rune) bool {
+	switch r {
+	case ' ', '-', '\u2010', '\'', '\u2019', '.', '\u30fb':
+		return true
+	}
+	return false
+}
+
+// checkDisplayName validates the name a user wants to be shown as. It is looser than checkName:
+// digits, punctuation and symbols such as emoji are welcome and scripts may be mixed. Only control
+// and formatting characters are rejected, apart from the zero width joiners emoji sequences need,
+// and words are separated by single spaces.
+func checkDisplayName(name string) string {
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return fmt.Sprintf("length must be between 1 and %d characters", maxNameLength)
+	}
+
+	var previous rune
+	for _, r := range name {
+		switch {
+		case r == ' ':
+			if previous == ' ' {
+				return errNameInvalidCharacters
+			}
+		case r == '\u200c', r == '\u200d':
+		case unicode.IsSpace(r), unicode.IsControl(r), unicode.Is(unicode.Cf, r), !unicode.IsPrint(r):
+			return errNameInvalidCharacters
+		}
+		previous = r
+	}
+	return ""
+}
+
+// scriptOf returns the name of the script r belongs to, or an empty string for characters
+// shared between scripts
+func scriptOf(r rune) string {
+	for script, table := range unicode.Scripts {
+		if script != "Common" && script != "Inherited" && unicode.Is(table, r) {
+			return script
+		}
+	}
+	return ""
+}
+
+func isScriptCombination(scripts map[string]bool) bool {
+	for _, combination := range scriptCombinations {
+		matched := 0
+		for _, script := range combination {
+			if scripts[script] {
+				matched++
+			}
+		}
+		if matched == len(scripts) {
+			return true
+		}
+	}
+	return false
+}
+
+// DisplayName is the optional name a user is shown as, see checkDisplayName
+type DisplayName struct {
+	Inner_value string
+}
+
+// Set validates and stores a display name. An empty name clears it.
+func (n *DisplayName) Set(name string, ve *OperationErrors) {
+	name = strings.TrimSpace(name)
+	if name != "" {
+		if problem := checkDisplayName(name); problem != "" {
+			ve.AddValidationError("display_name", "display_name "+problem)
+			return
+		}
+	}
+	n.Inner_value = name
+}
+
+// PersonalName is an optional name field of the profile, like the given or family name
+type PersonalName struct {
+	Inner_value string
+}
+
+// Set validates and stores a name for the given field. An empty name clears the field.
+func (n *PersonalName) Set(field, name string, ve *OperationErrors) {
+	name = strings.TrimSpace(name)
+	if name != "" {
+		if problem := checkName(name); problem != "" {
+			ve.AddValidationError(field, field+" "+problem)
+			return
+		}
+	}
+	n.Inner_value = name
+}
+
+// localeRegex matches the language, script, region and variant subtags of a BCP 47 language tag
+var localeRegex = regexp.MustCompile(`^[a-z]{2,3}(-[a-z]{4})?(-([a-z]{2}|[0-9]{3}))?(-([a-z0-9]{5,8}|[0-9][a-z0-9]{3}))*$`)
+
+// Locale is a BCP 47 language tag like en-GB or zh-Hant-TW
+type Locale struct {
+	Inner_value string
+}
+
+// Set validates a language tag and stores it in its canonical case. An empty tag clears the locale.
+func (l *Locale) Set(tag string, ve *OperationErrors) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if tag == "" {
+		l.Inner_value = ""
+		return
+	}
+	if len(tag) > 35 || !localeRegex.MatchString(tag) {
+		ve.AddValidationError("locale", "locale must be a language tag like en-GB")
+		return
+	}
+
+	// language in lower case, script in title case, region in upper case
+	subtags := strings.Split(tag, "-")
+	for i, subtag := range subtags[1:] {
+		switch {
+		case len(subtag) == 4 && i == 0:
+			subtags[i+1] = strings.ToUpper(subtag[:1]) + subtag[1:]
+		case len(subtag) == 2:
+			subtags[i+1] = strings.ToUpper(subtag)
+		}
+	}
+	l.Inner_value = strings.Join(subtags, "-")
+}
+
+// TimeZone is the name of a zone in the IANA time zone database, like Europe/Berlin
+type TimeZone struct {
+	Inner_value string
+}
+
+// Set validates and stores a time zone name. An empty name clears the time zone.
+func (tz *TimeZone) Set(zone string, ve *OperationErrors) {
+	zone = strings.TrimSpace(zone)
+	if zone == "" {
+		tz.Inner_value = ""
+		return
+	}
+	// "Local" is the time zone of the server, not one the user can be in
+	if _, err := time.LoadLocation(zone); err != nil || zone == "Local" {
+		ve.AddValidationError("time_zone", "time_zone must be an IANA time zone like Europe/Berlin")
+		return
+	}
+	tz.Inner_value = zone
+}
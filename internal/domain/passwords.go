@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BcryptMaxBytes is the length past which bcrypt ignores the rest of a password
+const BcryptMaxBytes = 72
+
+// Character classes a PasswordPolicy can require
+const (
+	CharacterClassUpper  = "upper"
+	CharacterClassLower  = "lower"
+	CharacterClassDigit  = "digit"
+	CharacterClassSymbol = "symbol"
+)
+
+// CharacterClasses are the character classes a PasswordPolicy accepts
+var CharacterClasses = []string{CharacterClassUpper, CharacterClassLower, CharacterClassDigit, CharacterClassSymbol}
+
+// PasswordPolicy holds the rules new passwords have to follow
+type PasswordPolicy struct {
+	// MinLength is counted in characters, MaxLength in bytes as that is what bcrypt is limited by
+	MinLength int
+	MaxLength int
+	// RequiredClasses lists the CharacterClasses a password needs at least one character of
+	RequiredClasses []string
+	// RejectPersonalInfo rejects passwords containing the user's email address or a part of their name
+	RejectPersonalInfo bool
+	// DenyList holds common and breached passwords in lower case
+	DenyList map[string]struct{}
+}
+
+// DefaultPasswordPolicy is the policy passwords were held to before it could be configured
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{MinLength: 8, MaxLength: BcryptMaxBytes}
+}
+
+// Validate checks a password against every rule of the policy and adds an error to ve for each
+// rule it breaks. personalInfo holds the user's email address and names.
+func (p *PasswordPolicy) Validate(password string, personalInfo []string, ve *OperationErrors) {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		ve.AddValidationError("password", fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+	}
+	if len(password) > p.MaxLength {
+		ve.AddValidationError("password", fmt.Sprintf("password must not be longer than %d bytes", p.MaxLength))
+	}
+
+	for _, class := range p.RequiredClasses {
+		if !strings.ContainsFunc(password, characterClassMatcher(class)) {
+			ve.AddValidationError("password", fmt.Sprintf("password must contain at least one %s character", characterClassNames[class]))
+		}
+	}
+
+	lowered := strings.ToLower(password)
+	if p.RejectPersonalInfo && containsPersonalInfo(lowered, personalInfo) {
+		ve.AddValidationError("password", "password must not contain your email address or name")
+	}
+	if _, denied := p.DenyList[lowered]; denied {
+		ve.AddValidationError("password", "password is too common, choose a different one")
+	}
+}
+
+var characterClassNames = map[string]string{
+	CharacterClassUpper:  "upper case",
+	CharacterClassLower:  "lower case",
+	CharacterClassDigit:  "digit",
+	CharacterClassSymbol: "symbol",
+}
+
+func characterClassMatcher(class string) func(rune) bool {
+	switch class {
+	case CharacterClassUpper:
+		return unicode.IsUpper
+	case CharacterClassLower:
+		return unicode.IsLower
+	case CharacterClassDigit:
+		return unicode.IsDigit
+	default:
+		return func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+		}
+	}
+}
+
+// minPersonalInfoLength keeps short name parts like "Al" from ruling out too many passwords
+const minPersonalInfoLength = 3
+
+// containsPersonalInfo reports whether the lower cased password holds the email address, its
+// local part or any part of a name
+func containsPersonalInfo(password string, personalInfo []string) bool {
+	for _, info := range personalInfo {
+		info = strings.ToLower(info)
+		var parts []string
+		if at := strings.LastIndex(info, "@"); at >= 0 {
+			// the domain of the address is not personal, only the whole address and its local part are
+			parts = []string{info, info[:at]}
+		} else {
+			parts = strings.FieldsFunc(info, isNameSeparator)
+		}
+		for _, part := range parts {
+			if utf8.RuneCountInString(part) >= minPersonalInfoLength && strings.Contains(password, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LoadPasswordDenyList reads a file with one password per line. Empty lines and lines
+// starting with # are skipped.
+func LoadPasswordDenyList(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open password deny list: %w", err)
+	}
+	defer file.Close()
+
+	denyList := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denyList[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read password deny list: %w", err)
+	}
+
+	return denyList, nil
+}
@@ -5,11 +5,12 @@ import (
 	"errors"
 	"golang.org/x/crypto/bcrypt"
 	"regexp"
+	"strings"
 	"time"
 )
 
-const emailRegex = `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
-const nameRegex = `^[a-zA-Z\s]+$`
+// The top level domain may be an internationalized one in its punycode form
+const emailRegex = `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.([a-zA-Z]{2,}|xn--[a-zA-Z0-9-]+)$`
 
 type UserDomainModel struct {
 	ID        int64
@@ -19,15 +20,28 @@ type UserDomainModel struct {
 	Password  Password
 	Activated bool
 	Version   int
+
+	GivenName   PersonalName
+	FamilyName  PersonalName
+	DisplayName DisplayName
+	Locale      Locale
+	TimeZone    TimeZone
 }
 
 func (um *UserDomainModel) IntoUserModel() data.UserModel {
 	userResponse := data.UserModel{
-		ID:        um.ID,
-		CreatedAt: um.CreatedAt,
-		Name:      um.Name.Inner_value,
-		Email:     um.Email.Inner_value,
-		Password:  um.Password.PasswordHash,
+		ID:          um.ID,
+		CreatedAt:   um.CreatedAt,
+		Name:        um.Name.Inner_value,
+		Email:       um.Email.Inner_value,
+		Password:    um.Password.PasswordHash,
+		Activated:   um.Activated,
+		Version:     um.Version,
+		GivenName:   um.GivenName.Inner_value,
+		FamilyName:  um.FamilyName.Inner_value,
+		DisplayName: um.DisplayName.Inner_value,
+		Locale:      um.Locale.Inner_value,
+		TimeZone:    um.TimeZone.Inner_value,
 	}
 	return userResponse
 }
@@ -38,21 +52,17 @@ type name struct {
 
 // Set method for setting the name with validation.
 func (n *name) Set(name string, ve *OperationErrors) {
+	name = strings.TrimSpace(name)
+
 	// Check if the name is empty
 	if name == "" {
 		ve.AddValidationError("name", "name must not be empty")
 		return
 	}
 
-	// Check if the name length is reasonable (between 1 and 100 characters)
-	if len(name) < 1 || len(name) > 100 {
-		ve.AddValidationError("name", "name length must be between 1 and 100 characters")
-		return
-	}
-
-	// Optionally, check for any invalid characters (e.g., numbers, special symbols)
-	if !isValidName(name) {
-		ve.AddValidationError("name", "name contains invalid characters")
+	// Check the length and that it only holds letters and the punctuation found in names
+	if problem := checkName(name); problem != "" {
+		ve.AddValidationError("name", "name "+problem)
 		return
 	}
 
@@ -60,14 +70,6 @@ func (n *name) Set(name string, ve *OperationErrors) {
 	n.Inner_value = name
 }
 
-// Helper function to check if the name contains valid characters.
-func isValidName(name string) bool {
-	// Simple regex to allow only alphabets, spaces, and basic punctuation (you can adjust this)
-	namePattern := nameRegex
-	re := regexp.MustCompile(namePattern)
-	return re.MatchString(name)
-}
-
 type email struct {
 	Inner_value string
 }
@@ -106,10 +108,16 @@ type Password struct {
 	PasswordHash []byte
 }
 
-func (p *Password) Set(plainTextPassword string, ve *OperationErrors) {
-	if len(plainTextPassword) < 8 {
-		ve.AddValidationError("password", "password must be at least 8 characters long")
-		return
+// Set checks the password against the policy and stores its hash. personalInfo holds the
+// user's email address and names for the policy to check against. A nil policy only hashes
+// the password, for passwords that were already accepted before.
+func (p *Password) Set(plainTextPassword string, policy *PasswordPolicy, personalInfo []string, ve *OperationErrors) {
+	if policy != nil {
+		errorsBefore := len(ve.Validation["password"])
+		policy.Validate(plainTextPassword, personalInfo, ve)
+		if len(ve.Validation["password"]) > errorsBefore {
+			return
+		}
 	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(plainTextPassword), bcrypt.DefaultCost)
 	if err != nil {
@@ -120,6 +128,15 @@ func (p *Password) Set(plainTextPassword string, ve *OperationErrors) {
 	p.PasswordHash = hash
 }
 
+// dummyPasswordHash stands in for the password hash of an account that does not exist
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("no such account"), bcrypt.DefaultCost)
+
+// MatchNoAccount takes as long to check a password as Matches does for a real account, so a
+// login for an unknown email cannot be told apart by its response time. Nothing ever matches.
+func MatchNoAccount(plaintextPassword string) {
+	_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(plaintextPassword))
+}
+
 func (p *Password) Matches(plaintextPassword string) (bool, error) {
 	err := bcrypt.CompareHashAndPassword(p.PasswordHash, []byte(plaintextPassword))
 	if err != nil {
@@ -0,0 +1,74 @@
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mailer delivers plain text emails to users
+type Mailer interface {
+	Send(recipient, subject, body string) error
+}
+
+// LogMailer writes emails to the log instead of sending them. It is used in
+// development and whenever no SMTP server is configured.
+type LogMailer struct {
+	Logger *slog.Logger
+}
+
+func NewLogMailer(logger *slog.Logger) *LogMailer {
+	return &LogMailer{Logger: logger}
+}
+
+func (m *LogMailer) Send(recipient, subject, body string) error {
+	m.Logger.Info("Email not sent, no SMTP server configured", "recipient", recipient, "subject", subject, "body", body)
+	return nil
+}
+
+// SMTPMailer sends emails through an SMTP server, authenticating with PLAIN auth when a username is set
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Sender   string
+}
+
+func NewSMTPMailer(host string, port int, username, password, sender string) *SMTPMailer {
+	return &SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		Sender:   sender,
+	}
+}
+
+func (m *SMTPMailer) Send(recipient, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.Sender)
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	err := smtp.SendMail(addr, auth, m.Sender, []string{recipient}, []byte(msg.String()))
+	if err != nil {
+		return fmt.Errorf("could not send email: %w", err)
+	}
+
+	return nil
+}
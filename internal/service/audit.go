@@ -0,0 +1,69 @@
+package service
+
+import (
+	"authentication-service/internal/data"
+	"fmt"
+	"time"
+)
+
+// Audited actions
+const (
+	AuditLogin                    = "login"
+	AuditLogout                   = "logout"
+	AuditLogoutAll                = "logout.all"
+	AuditPasswordChanged          = "password.changed"
+	AuditPasswordReset            = "password.reset"
+	AuditEmailChangeRequested     = "email.change_requested"
+	AuditEmailChanged             = "email.changed"
+	AuditEmailChangeCancelled     = "email.change_cancelled"
+	AuditPersonalTokenCreated     = "personal_token.created"
+	AuditPersonalTokenRevoked     = "personal_token.revoked"
+	AuditAccountDeletionRequested = "account.deletion_requested"
+	AuditAccountRestored          = "account.restored"
+	AuditDataExported             = "account.exported"
+	AuditAccountLocked            = "account.locked"
+	AuditAccountUnlocked          = "account.unlocked"
+	AuditAccountSuspended         = "account.suspended"
+	AuditAccountReinstated        = "account.reinstated"
+)
+
+type AuditService struct {
+	RepoManager *data.RepoManager
+}
+
+func NewAuditService(repoManager *data.RepoManager) *AuditService {
+	return &AuditService{RepoManager: repoManager}
+}
+
+// Record appends an action taken on a user's account to the audit log
+func (s *AuditService) Record(userID int64, action, ip, userAgent string) error {
+	_, err := s.RepoManager.AuditRepo.Insert(&data.AuditEntry{
+		UserID:    userID,
+		Action:    action,
+		IP:        ip,
+		UserAgent: userAgent,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("could not record audit entry: %w", err)
+	}
+	return nil
+}
+
+func (s *AuditService) ListForUser(userID int64) ([]AuditEntryResponse, error) {
+	entries, err := s.RepoManager.AuditRepo.GetAllForUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve audit log: %w", err)
+	}
+
+	res := make([]AuditEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		res = append(res, AuditEntryResponse{
+			Action:    entry.Action,
+			IP:        entry.IP,
+			UserAgent: entry.UserAgent,
+			CreatedAt: entry.CreatedAt,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"authentication-service/internal/data"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidClient = errors.New("invalid client credentials")
+
+type ClientService struct {
+	RepoManager *data.RepoManager
+}
+
+func NewClientService(repoManager *data.RepoManager) *ClientService {
+	return &ClientService{RepoManager: repoManager}
+}
+
+// CreateClient registers a new client. The secret is only returned here, the database keeps its hash.
+func (s *ClientService) CreateClient(name string) (*ClientResponse, error) {
+	clientID, err := data.GenerateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := data.GenerateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.RepoManager.ClientRepo.Insert(&data.ClientModel{
+		ID:         clientID,
+		Name:       name,
+		SecretHash: data.HashToken(secret),
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create client: %w", err)
+	}
+
+	return &ClientResponse{
+		ID:        client.ID,
+		Name:      client.Name,
+		Secret:    secret,
+		CreatedAt: client.CreatedAt,
+	}, nil
+}
+
+// AuthenticateClient checks a client ID and secret pair
+func (s *ClientService) AuthenticateClient(clientID, secret string) (*ClientResponse, error) {
+	client, err := s.RepoManager.ClientRepo.GetByID(clientID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(client.SecretHash, data.HashToken(secret)) != 1 {
+		return nil, ErrInvalidClient
+	}
+
+	return &ClientResponse{
+		ID:        client.ID,
+		Name:      client.Name,
+		CreatedAt: client.CreatedAt,
+	}, nil
+}
+
+func (s *ClientService) ListClients() ([]ClientResponse, error) {
+	models, err := s.RepoManager.ClientRepo.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve clients: %w", err)
+	}
+
+	clients := make([]ClientResponse, 0, len(models))
+	for _, model := range models {
+		clients = append(clients, ClientResponse{
+			ID:        model.ID,
+			Name:      model.Name,
+			CreatedAt: model.CreatedAt,
+		})
+	}
+
+	return clients, nil
+}
+
+func (s *ClientService) DeleteClient(clientID string) error {
+	return s.RepoManager.ClientRepo.Delete(clientID)
+}
@@ -0,0 +1,351 @@
+package service
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SigningKey holds the key material used to sign and verify JWTs.
+// For HMAC the same secret is used for both, for the asymmetric
+// algorithms only the public half is ever handed out.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	material  []byte
+	signKey   any
+	verifyKey any
+	retireAt  time.Time
+}
+
+// KeyRing holds every key that is currently accepted for verification,
+// together with the single key used to sign new tokens.
+type KeyRing struct {
+	mu       sync.RWMutex
+	active   *SigningKey
+	keys     map[string]*SigningKey
+	loadedAt time.Time
+}
+
+// JWK is the public representation of a verification key (RFC 7517)
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewHMACSigningKey creates an HS256 key from a shared secret
+func NewHMACSigningKey(secret string) (*SigningKey, error) {
+	if secret == "" {
+		return nil, errors.New("HS256 requires a non-empty secret")
+	}
+	key := &SigningKey{
+		Method:    jwt.SigningMethodHS256,
+		material:  []byte(secret),
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+	key.ID = key.thumbprint()
+	return key, nil
+}
+
+// GenerateSigningKey creates a fresh random key for the given algorithm
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var privateKey any
+	var err error
+
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		secret := make([]byte, 32)
+		if _, err = rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("could not generate secret: %w", err)
+		}
+		return NewHMACSigningKey(string(secret))
+	case jwt.SigningMethodRS256.Alg():
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodES256.Alg():
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not generate private key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode private key: %w", err)
+	}
+	return ParseSigningKey(algorithm, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// sealedKeyPrefix marks stored key material that is encrypted with the key encryption key
+var sealedKeyPrefix = []byte("aes256gcm:")
+
+// ErrMissingKeyEncryptionKey is returned for encrypted key material when no key encryption key is configured
+var ErrMissingKeyEncryptionKey = errors.New("signing key is encrypted but no key encryption key is configured")
+
+// sealKeyMaterial encrypts key material with AES-256-GCM under the key encryption key.
+// The kid is authenticated along with it, so a sealed key cannot be moved to another row.
+func sealKeyMaterial(kek []byte, kid string, material []byte) ([]byte, error) {
+	aead, err := newKeyAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("could not generate nonce: %w", err)
+	}
+
+	sealed := append(append([]byte{}, sealedKeyPrefix...), nonce...)
+	return aead.Seal(sealed, nonce, material, []byte(kid)), nil
+}
+
+// openKeyMaterial reverses sealKeyMaterial. Material stored before keys were encrypted is
+// returned as is, with sealed set to false.
+func openKeyMaterial(kek []byte, kid string, stored []byte) (material []byte, sealed bool, err error) {
+	if !bytes.HasPrefix(stored, sealedKeyPrefix) {
+		return stored, false, nil
+	}
+	if kek == nil {
+		return nil, true, ErrMissingKeyEncryptionKey
+	}
+	aead, err := newKeyAEAD(kek)
+	if err != nil {
+		return nil, true, err
+	}
+
+	stored = stored[len(sealedKeyPrefix):]
+	if len(stored) < aead.NonceSize() {
+		return nil, true, errors.New("encrypted signing key is truncated")
+	}
+	material, err = aead.Open(nil, stored[:aead.NonceSize()], stored[aead.NonceSize():], []byte(kid))
+	if err != nil {
+		return nil, true, errors.New("could not decrypt signing key, is the key encryption key right?")
+	}
+	return material, true, nil
+}
+
+func newKeyAEAD(kek []byte) (cipher.AEAD, error) {
+	if len(kek) != 32 {
+		return nil, errors.New("the key encryption key must be 32 bytes long")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// LoadSigningKey reads a PEM encoded private key from disk
+func LoadSigningKey(algorithm string, path string) (*SigningKey, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%s requires a private key file", algorithm)
+	}
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read private key: %w", err)
+	}
+	return ParseSigningKey(algorithm, pemBytes)
+}
+
+// ParseSigningKey builds a signing key from its stored material: the shared secret
+// for HS256, or a PEM encoded private key for RS256, ES256 and EdDSA
+func ParseSigningKey(algorithm string, pemBytes []byte) (*SigningKey, error) {
+	var key *SigningKey
+
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		return NewHMACSigningKey(string(pemBytes))
+
+	case jwt.SigningMethodRS256.Alg():
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse RSA private key: %w", err)
+		}
+		if privateKey.N.BitLen() < 2048 {
+			return nil, errors.New("RSA private key must be at least 2048 bits")
+		}
+		key = &SigningKey{Method: jwt.SigningMethodRS256, signKey: privateKey, verifyKey: &privateKey.PublicKey}
+
+	case jwt.SigningMethodES256.Alg():
+		privateKey, err := jwt.ParseECPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse EC private key: %w", err)
+		}
+		if privateKey.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 requires a P-256 private key")
+		}
+		key = &SigningKey{Method: jwt.SigningMethodES256, signKey: privateKey, verifyKey: &privateKey.PublicKey}
+
+	case jwt.SigningMethodEdDSA.Alg():
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse Ed25519 private key: %w", err)
+		}
+		edKey, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("EdDSA requires an Ed25519 private key")
+		}
+		key = &SigningKey{Method: jwt.SigningMethodEdDSA, signKey: edKey, verifyKey: edKey.Public()}
+
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	key.material = pemBytes
+	key.ID = key.thumbprint()
+	return key, nil
+}
+
+// keyFunc returns the verification key, refusing tokens signed with any other algorithm
+func (k *SigningKey) keyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != k.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return k.verifyKey, nil
+}
+
+// JWK returns the public key in JWK form. HMAC secrets are never published.
+func (k *SigningKey) JWK() (JWK, bool) {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+
+	switch publicKey := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeJWKBytes(publicKey.N.Bytes())
+		jwk.E = encodeJWKBytes(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = publicKey.Curve.Params().Name
+		jwk.X = encodeJWKBytes(publicKey.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeJWKBytes(publicKey.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encodeJWKBytes(publicKey)
+	default:
+		return JWK{}, false
+	}
+
+	return jwk, true
+}
+
+func (k *SigningKey) retired() bool {
+	return !k.retireAt.IsZero() && time.Now().After(k.retireAt)
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint, which is used as the key ID
+func (k *SigningKey) thumbprint() string {
+	var members string
+
+	if secret, ok := k.verifyKey.([]byte); ok {
+		members = fmt.Sprintf(`{"k":%q,"kty":"oct"}`, encodeJWKBytes(secret))
+	} else {
+		jwk, _ := k.JWK()
+		switch jwk.Kty {
+		case "RSA":
+			members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+		case "EC":
+			members = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, jwk.Crv, jwk.X, jwk.Y)
+		case "OKP":
+			members = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, jwk.Crv, jwk.X)
+		}
+	}
+
+	sum := sha256.Sum256([]byte(members))
+	return encodeJWKBytes(sum[:])
+}
+
+// Set replaces the contents of the key ring
+func (r *KeyRing) Set(active *SigningKey, keys []*SigningKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.active = active
+	r.keys = make(map[string]*SigningKey, len(keys))
+	for _, key := range keys {
+		r.keys[key.ID] = key
+	}
+	r.loadedAt = time.Now()
+}
+
+// Active returns the key new tokens are signed with, or nil once that key has been retired
+func (r *KeyRing) Active() *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.active == nil || r.active.retired() {
+		return nil
+	}
+	return r.active
+}
+
+// Get looks up a verification key by its ID
+func (r *KeyRing) Get(kid string) (*SigningKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[kid]
+	if !ok || key.retired() {
+		return nil, false
+	}
+	return key, true
+}
+
+// LoadedAt reports when the key ring was last refreshed
+func (r *KeyRing) LoadedAt() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.loadedAt
+}
+
+// JWKS returns the public keys of every key still accepted for verification
+func (r *KeyRing) JWKS() JWKSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range r.keys {
+		if key.retired() {
+			continue
+		}
+		if jwk, ok := key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+func encodeJWKBytes(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
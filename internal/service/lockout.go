@@ -0,0 +1,56 @@
+package service
+
+import "time"
+
+// IsLocked reports whether the user is locked out of logging in and until when
+func (s *UserService) IsLocked(user *UserResponse) (time.Time, bool) {
+	if user.LockedUntil == nil || !time.Now().Before(*user.LockedUntil) {
+		return time.Time{}, false
+	}
+	return *user.LockedUntil, true
+}
+
+// RecordFailedLogin counts a failed login for the user. Once the failures reach the lockout
+// threshold the account is locked, and it returns when the lockout ends.
+func (s *UserService) RecordFailedLogin(userID int64) (time.Time, bool, error) {
+	if s.Config.LockoutThreshold <= 0 {
+		return time.Time{}, false, nil
+	}
+
+	failures, err := s.RepoManager.UserRepo.RecordFailedLogin(userID, time.Now().Add(-s.Config.LockoutWindow))
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if failures < s.Config.LockoutThreshold {
+		return time.Time{}, false, nil
+	}
+
+	until := time.Now().Add(s.lockoutDuration(failures))
+	err = s.RepoManager.UserRepo.LockUntil(userID, until)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return until, true, nil
+}
+
+// lockoutDuration doubles the lockout for every failure past the threshold, up to the maximum
+func (s *UserService) lockoutDuration(failures int) time.Duration {
+	duration := s.Config.LockoutDuration
+	for i := s.Config.LockoutThreshold; i < failures; i++ {
+		if duration >= s.Config.LockoutMaxDuration/2 {
+			return s.Config.LockoutMaxDuration
+		}
+		duration *= 2
+	}
+	return min(duration, s.Config.LockoutMaxDuration)
+}
+
+// ResetFailedLogins clears the failed login count after a successful login
+func (s *UserService) ResetFailedLogins(userID int64) error {
+	return s.RepoManager.UserRepo.ResetFailedLogins(userID)
+}
+
+// UnlockUser lifts a lockout of the user at the given version before it runs out
+func (s *UserService) UnlockUser(userID int64, version int) error {
+	return s.RepoManager.UserRepo.Unlock(userID, version)
+}
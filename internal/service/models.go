@@ -1,5 +1,10 @@
 package service
 
+import (
+	"authentication-service/internal/data"
+	"time"
+)
+
 // ------------------------------
 
 type LoginInput struct {
@@ -8,7 +13,56 @@ type LoginInput struct {
 }
 
 type LoginInResponse struct {
+	SessionID          string `json:"session_id"`
 	AuthorizationToken string `json:"authorization_token"`
+	RefreshToken       string `json:"refresh_token"`
+	ExpiresIn          int64  `json:"expires_in"`
+	// PendingSteps lists what the user still has to do before the account is fully usable
+	PendingSteps []PendingStep `json:"pending_steps,omitempty"`
+}
+
+// PendingStep is something a user has to do before they can use their account without restrictions
+type PendingStep struct {
+	Step        string     `json:"step"`
+	Description string     `json:"description"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword    string `json:"current_password"`
+	NewPassword        string `json:"new_password"`
+	KeepCurrentSession bool   `json:"keep_current_session"`
+}
+
+type ChangeEmailInput struct {
+	NewEmail string `json:"new_email"`
+	Password string `json:"password"`
+}
+
+type EmailChangeTokenInput struct {
+	Token string `json:"token"`
+}
+
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // -------------------------------
@@ -21,6 +75,10 @@ type DeletePermissionInput struct {
 	Permission string `json:"permission"`
 }
 
+type RoleInput struct {
+	Role string `json:"role"`
+}
+
 //------------------------------
 
 type RegenerateEmailTokenInput struct {
@@ -37,8 +95,62 @@ type ValidateTokenInput struct {
 	Token string `json:"token"`
 }
 type ValidateTokenResponse struct {
-	Token   string `json:"token"`
-	IsValid bool   `json:"is_valid"`
+	IsValid bool `json:"is_valid"`
+}
+
+type SigningKeyResponse struct {
+	ID        string     `json:"kid"`
+	Algorithm string     `json:"algorithm"`
+	CreatedAt time.Time  `json:"created_at"`
+	Active    bool       `json:"active"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+}
+
+//---------------------------------
+
+type CreateClientInput struct {
+	Name string `json:"name"`
+}
+
+type ClientResponse struct {
+	ID        string    `json:"client_id"`
+	Name      string    `json:"name"`
+	Secret    string    `json:"client_secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+//---------------------------------
+
+type CreatePersonalAccessTokenInput struct {
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+type PersonalAccessTokenResponse struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Token       string           `json:"token,omitempty"`
+	Permissions data.Permissions `json:"permissions"`
+	CreatedAt   time.Time        `json:"created_at"`
+	ExpiresAt   *time.Time       `json:"expires_at"`
+	LastUsedAt  *time.Time       `json:"last_used_at"`
+}
+
+// IntrospectionResponse follows RFC 7662. Inactive tokens only ever report "active": false.
+type IntrospectionResponse struct {
+	Active      bool             `json:"active"`
+	Sub         string           `json:"sub,omitempty"`
+	Scope       string           `json:"scope,omitempty"`
+	Exp         int64            `json:"exp,omitempty"`
+	Iat         int64            `json:"iat,omitempty"`
+	Nbf         int64            `json:"nbf,omitempty"`
+	Iss         string           `json:"iss,omitempty"`
+	Aud         string           `json:"aud,omitempty"`
+	Jti         string           `json:"jti,omitempty"`
+	ClientID    string           `json:"client_id,omitempty"`
+	Permissions data.Permissions `json:"permissions,omitempty"`
+	UserID      int64            `json:"-"`
 }
 
 //---------------------------------
@@ -48,13 +160,124 @@ type UserRegisterInput struct {
 	Email     string `json:"email"`
 	Password  string `json:"password"`
 	Activated bool   `json:"activated"`
+	// Version is the version an update applies to, registrations ignore it
+	Version *int `json:"version,omitempty"`
 }
 
 type UserResponse struct {
-	ID                int64  `json:"id"`
-	Name              string `json:"name"`
-	Email             string `json:"email"`
-	VerificationToken string `json:"verification_token"`
-	Password          []byte `json:"-"`
-	Activated         bool   `json:"activated"`
+	ID                int64           `json:"id"`
+	Name              string          `json:"name"`
+	Email             string          `json:"email"`
+	VerificationToken string          `json:"verification_token"`
+	Password          []byte          `json:"-"`
+	Activated         bool            `json:"activated"`
+	PendingEmail      string          `json:"pending_email,omitempty"`
+	CreatedAt         time.Time       `json:"-"`
+	Version           int             `json:"-"`
+	DeletedAt         *time.Time      `json:"-"`
+	FailedLogins      int             `json:"-"`
+	LockedUntil       *time.Time      `json:"-"`
+	Status            data.UserStatus `json:"-"`
+	GivenName         string          `json:"-"`
+	FamilyName        string          `json:"-"`
+	DisplayName       string          `json:"-"`
+	Locale            string          `json:"-"`
+	TimeZone          string          `json:"-"`
+}
+
+// ProfileResponse describes the authenticated user to themselves
+type ProfileResponse struct {
+	ID           int64            `json:"id"`
+	Name         string           `json:"name"`
+	GivenName    string           `json:"given_name"`
+	FamilyName   string           `json:"family_name"`
+	DisplayName  string           `json:"display_name"`
+	Locale       string           `json:"locale"`
+	TimeZone     string           `json:"time_zone"`
+	Email        string           `json:"email"`
+	PendingEmail string           `json:"pending_email,omitempty"`
+	Activated    bool             `json:"activated"`
+	CreatedAt    time.Time        `json:"created_at"`
+	Version      int              `json:"version"`
+	Permissions  data.Permissions `json:"permissions"`
+	Roles        []string         `json:"roles"`
+	Status       data.UserStatus  `json:"status"`
+	DeletedAt    *time.Time       `json:"deleted_at,omitempty"`
+}
+
+type AuditEntryResponse struct {
+	Action    string    `json:"action"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DeleteAccountInput struct {
+	Password string `json:"password"`
+}
+
+type SuspendUserInput struct {
+	Reason string `json:"reason"`
+}
+
+// EmailNormalizationReport sums up a run of UserService.NormalizeStoredEmails
+type EmailNormalizationReport struct {
+	Updated int
+	// Duplicates maps a normalized address to the users that would share it
+	Duplicates map[string][]int64
+	// Invalid lists the users whose address has a domain IDNA refuses
+	Invalid []int64
+}
+
+// UserExport is the archive of everything stored about a user, handed out on request
+type UserExport struct {
+	ExportedAt           time.Time                     `json:"exported_at"`
+	Profile              *ProfileResponse              `json:"profile"`
+	Sessions             []SessionResponse             `json:"sessions"`
+	PersonalAccessTokens []PersonalAccessTokenResponse `json:"personal_access_tokens"`
+	AuditLog             []AuditEntryResponse          `json:"audit_log"`
+}
+
+// UserSummary is how users are listed to administrators
+type UserSummary struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Email     string          `json:"email"`
+	Activated bool            `json:"activated"`
+	CreatedAt time.Time       `json:"created_at"`
+	Version   int             `json:"version"`
+	Status    data.UserStatus `json:"status"`
+	DeletedAt *time.Time      `json:"deleted_at,omitempty"`
+	// SuspendedAt and SuspensionReason are only set for suspended users
+	SuspendedAt      *time.Time `json:"suspended_at,omitempty"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
+}
+
+// Metadata describes where a page sits within a paginated listing
+type Metadata struct {
+	CurrentPage  int `json:"current_page,omitempty"`
+	PageSize     int `json:"page_size,omitempty"`
+	FirstPage    int `json:"first_page,omitempty"`
+	LastPage     int `json:"last_page,omitempty"`
+	TotalRecords int `json:"total_records"`
+}
+
+// PasswordPolicyResponse describes the rules new passwords have to follow, for UIs to show them
+type PasswordPolicyResponse struct {
+	MinLength          int      `json:"min_length"`
+	MaxLengthBytes     int      `json:"max_length_bytes"`
+	RequiredClasses    []string `json:"required_character_classes"`
+	RejectPersonalInfo bool     `json:"reject_personal_info"`
+	// DenyList tells whether common and breached passwords are rejected
+	DenyList bool `json:"deny_list"`
+}
+
+// UpdateProfileInput holds a partial profile update, fields left out are not changed
+type UpdateProfileInput struct {
+	Name        *string `json:"name"`
+	GivenName   *string `json:"given_name"`
+	FamilyName  *string `json:"family_name"`
+	DisplayName *string `json:"display_name"`
+	Locale      *string `json:"locale"`
+	TimeZone    *string `json:"time_zone"`
 }
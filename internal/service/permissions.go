@@ -65,3 +65,49 @@ func (s *PermissionsService) GetPermissionsForUser(userID int64) (data.Permissio
 
 	return permissions, nil
 }
+
+// AddRoleToUser assigns a role to a user, creating the role if it does not exist yet.
+// Roles are labels for downstream services, access inside this service is decided by permissions.
+func (s *PermissionsService) AddRoleToUser(userID int64, role string) error {
+	roleID, err := s.RepoManager.PermissionsRepo.GetRoleIDByName(role)
+	if err != nil {
+		if err := s.RepoManager.PermissionsRepo.InsertRole(role); err != nil {
+			return fmt.Errorf("could not add role: %w", err)
+		}
+
+		roleID, err = s.RepoManager.PermissionsRepo.GetRoleIDByName(role)
+		if err != nil {
+			return fmt.Errorf("could not retrieve role ID: %w", err)
+		}
+	}
+
+	err = s.RepoManager.PermissionsRepo.InsertUserRole(userID, roleID)
+	if err != nil {
+		return fmt.Errorf("could not assign role to user: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PermissionsService) RemoveRole(userID int64, role string) error {
+	roleID, err := s.RepoManager.PermissionsRepo.GetRoleIDByName(role)
+	if err != nil {
+		return fmt.Errorf("could not retrieve role ID: %w", err)
+	}
+
+	err = s.RepoManager.PermissionsRepo.DeleteUserRole(userID, roleID)
+	if err != nil {
+		return fmt.Errorf("could not remove role from user: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PermissionsService) GetRolesForUser(userID int64) ([]string, error) {
+	roles, err := s.RepoManager.PermissionsRepo.GetRolesForUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve user roles: %w", err)
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"authentication-service/internal/data"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PersonalAccessTokenPrefix marks personal access tokens so they can be told apart from
+// session tokens, and picked up by secret scanners when they leak
+const PersonalAccessTokenPrefix = "pat_"
+
+var (
+	ErrInvalidPersonalAccessToken = errors.New("invalid or expired personal access token")
+	ErrPermissionNotHeld          = errors.New("a personal access token cannot be granted permissions its owner does not hold")
+)
+
+type PersonalAccessTokenService struct {
+	RepoManager *data.RepoManager
+}
+
+func NewPersonalAccessTokenService(repoManager *data.RepoManager) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{RepoManager: repoManager}
+}
+
+// IsPersonalAccessToken reports whether a bearer token is a personal access token
+func IsPersonalAccessToken(tokenString string) bool {
+	return strings.HasPrefix(tokenString, PersonalAccessTokenPrefix)
+}
+
+// CreatePersonalAccessToken issues a named token limited to the given permissions, every one
+// of which the user has to hold. The plaintext token is only returned here.
+func (s *PersonalAccessTokenService) CreatePersonalAccessToken(userID int64, input CreatePersonalAccessTokenInput) (*PersonalAccessTokenResponse, error) {
+	held, err := s.RepoManager.PermissionsRepo.GetAllForUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve user permissions: %w", err)
+	}
+	permissions := data.Permissions{}
+	for _, permission := range input.Permissions {
+		if !held.HasPermission(permission) {
+			return nil, fmt.Errorf("%w: %s", ErrPermissionNotHeld, permission)
+		}
+		if !permissions.HasPermission(permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	id, err := data.GenerateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := data.GenerateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	plaintext := PersonalAccessTokenPrefix + secret
+
+	token, err := s.RepoManager.PersonalTokenRepo.Insert(&data.PersonalAccessToken{
+		ID:          id,
+		UserID:      userID,
+		Name:        input.Name,
+		Hash:        data.HashToken(plaintext),
+		Permissions: permissions,
+		CreatedAt:   time.Now(),
+		ExpiresAt:   input.ExpiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create personal access token: %w", err)
+	}
+
+	response := newPersonalAccessTokenResponse(token)
+	response.Token = plaintext
+	return response, nil
+}
+
+// AuthenticatePersonalAccessToken resolves a personal access token and records its use.
+// The permissions returned are those of the token that its owner still holds, so taking a
+// permission away from a user also takes it away from their tokens.
+func (s *PersonalAccessTokenService) AuthenticatePersonalAccessToken(tokenString string) (*data.PersonalAccessToken, data.Permissions, error) {
+	token, err := s.RepoManager.PersonalTokenRepo.GetByHash(data.HashToken(tokenString))
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidPersonalAccessToken
+		}
+		return nil, nil, err
+	}
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, nil, ErrInvalidPersonalAccessToken
+	}
+
+	held, err := s.RepoManager.PermissionsRepo.GetAllForUser(token.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not retrieve user permissions: %w", err)
+	}
+	permissions := data.Permissions{}
+	for _, permission := range token.Permissions {
+		if held.HasPermission(permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	err = s.RepoManager.PersonalTokenRepo.Touch(token.ID, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return token, permissions, nil
+}
+
+func (s *PersonalAccessTokenService) ListPersonalAccessTokens(userID int64) ([]PersonalAccessTokenResponse, error) {
+	models, err := s.RepoManager.PersonalTokenRepo.GetAllForUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve personal access tokens: %w", err)
+	}
+
+	tokens := make([]PersonalAccessTokenResponse, 0, len(models))
+	for i := range models {
+		tokens = append(tokens, *newPersonalAccessTokenResponse(&models[i]))
+	}
+
+	return tokens, nil
+}
+
+func (s *PersonalAccessTokenService) RevokePersonalAccessToken(userID int64, tokenID string) error {
+	return s.RepoManager.PersonalTokenRepo.Delete(userID, tokenID)
+}
+
+// RevokeAllPersonalAccessTokens deletes every personal access token of a user
+func (s *PersonalAccessTokenService) RevokeAllPersonalAccessTokens(userID int64) error {
+	return s.RepoManager.PersonalTokenRepo.DeleteAllForUser(userID)
+}
+
+func newPersonalAccessTokenResponse(token *data.PersonalAccessToken) *PersonalAccessTokenResponse {
+	permissions := token.Permissions
+	if permissions == nil {
+		permissions = data.Permissions{}
+	}
+	return &PersonalAccessTokenResponse{
+		ID:          token.ID,
+		Name:        token.Name,
+		Permissions: permissions,
+		CreatedAt:   token.CreatedAt,
+		ExpiresAt:   token.ExpiresAt,
+		LastUsedAt:  token.LastUsedAt,
+	}
+}
@@ -9,38 +9,112 @@ import (
 type UserServiceInterface interface {
 	RegisterUser(input *UserRegisterInput) (*UserResponse, *domain.OperationErrors)
 	GetUserByEmail(email string) (*UserResponse, *domain.OperationErrors)
-	UpdateUser(input *UserRegisterInput) *domain.OperationErrors
+	UpdateUser(input *UserRegisterInput, version int) error
 	ValidateUser(input RegenerateEmailTokenInput) (*ReGenerateEmailTokenResponse, error)
 	GetUserByID(userId int64) (*UserResponse, *domain.OperationErrors)
 	UpdateUserActivationStatus(userID int64, status bool) error
+	SetPassword(userID int64, plainTextPassword string) *domain.OperationErrors
+	ValidateNewPassword(userID int64, plainTextPassword string) *domain.OperationErrors
+	ChangePassword(userID int64, input ChangePasswordInput) *domain.OperationErrors
+	PasswordPolicy() PasswordPolicyResponse
+	RequestEmailChange(userID int64, input ChangeEmailInput) (*UserResponse, *domain.OperationErrors)
+	ConfirmEmailChange(userID int64) (*UserResponse, error)
+	CancelEmailChange(userID int64) error
+	UpdateProfile(userID int64, version int, input UpdateProfileInput) error
+	ListUsers(filter data.UserFilter) ([]UserSummary, Metadata, *domain.OperationErrors)
+	CheckPassword(userID int64, plainTextPassword string) (bool, error)
+	ScheduleDeletion(userID int64, version int) error
+	RestoreUser(userID int64, version int) error
+	SuspendUser(userID int64, version int, input SuspendUserInput) error
+	ReinstateUser(userID int64, version int) error
+	DeleteUser(userID int64, version int) error
+	PurgeDeletedUsers(before time.Time) (int, error)
+	NormalizeStoredEmails() (*EmailNormalizationReport, error)
+	IsLocked(user *UserResponse) (time.Time, bool)
+	RecordFailedLogin(userID int64) (time.Time, bool, error)
+	ResetFailedLogins(userID int64) error
+	UnlockUser(userID int64, version int) error
+	EmailVerificationStatus(user *UserResponse) (*PendingStep, error)
+	RestrictPermissions(user *UserResponse, permissions data.Permissions) data.Permissions
 }
 
 type TokenServiceInterface interface {
-	CreateAccessToken(userID int64, scope data.TokenScope, ttl time.Duration, secret string) (string, error)
+	CreateAccessToken(userID int64, scope data.TokenScope, ttl time.Duration) (string, error)
 
-	ValidateToken(tokenString string, secret string) (bool, error)
+	ValidateToken(tokenString string, scope data.TokenScope) (bool, error)
+	ExtractUserID(tokenString string) (int64, error)
+	ClaimedPermissions(tokenString string) (data.Permissions, bool)
+	JWKS() JWKSet
+	RotateSigningKey() (*SigningKey, error)
+	RetireSigningKey(kid string) error
+	ListSigningKeys() ([]SigningKeyResponse, error)
 	GetTokensForUser(userID int64) ([]data.Token, error)
 	GetTokensForUserAndScope(userID int64, scope data.TokenScope) ([]data.Token, error)
 	DeleteToken(tokenHash []byte) error
 	InsertToken(token *data.Token) (*data.Token, error)
 	DeleteTokensForUser(userId int64, scope data.TokenScope) error
+	CreateTokenPair(userID int64, family string, accessTTL, refreshTTL time.Duration) (*LoginInResponse, error)
+	UseRefreshToken(tokenString string) (*data.Token, error)
+	CreateOneTimeToken(userID int64, scope data.TokenScope, ttl time.Duration) (string, error)
+	AddOneTimeToken(userID int64, scope data.TokenScope, ttl time.Duration) (string, error)
+	ConsumeOneTimeToken(tokenString string, scope data.TokenScope) (*data.Token, error)
+	GetActiveToken(tokenString string, scope data.TokenScope) (*data.Token, error)
+	RevokeToken(token *data.Token) error
+	RevokeUserTokens(userID int64) error
+	CreateSession(userID int64, userAgent, ip string) (*data.Session, error)
+	ListSessions(userID int64, currentSessionID string) ([]SessionResponse, error)
+	RevokeSession(userID int64, sessionID string) error
+	RevokeOtherSessions(userID int64, keepSessionID string) error
+	TouchSession(sessionID string) error
+	PruneSessions() (int64, error)
+	LoadSigningKeys() error
+	Introspect(tokenString string) (*IntrospectionResponse, error)
 }
 type PermissionsServiceInterface interface {
 	AddPermission(permission string) error
 	AddPermissionToUser(userID int64, permission string) error
 	RemovePermission(userID int64, permission string) error
 	GetPermissionsForUser(userID int64) (data.Permissions, error)
+	AddRoleToUser(userID int64, role string) error
+	RemoveRole(userID int64, role string) error
+	GetRolesForUser(userID int64) ([]string, error)
 }
+type ClientServiceInterface interface {
+	CreateClient(name string) (*ClientResponse, error)
+	AuthenticateClient(clientID, secret string) (*ClientResponse, error)
+	ListClients() ([]ClientResponse, error)
+	DeleteClient(clientID string) error
+}
+
+type PersonalAccessTokenServiceInterface interface {
+	CreatePersonalAccessToken(userID int64, input CreatePersonalAccessTokenInput) (*PersonalAccessTokenResponse, error)
+	AuthenticatePersonalAccessToken(tokenString string) (*data.PersonalAccessToken, data.Permissions, error)
+	ListPersonalAccessTokens(userID int64) ([]PersonalAccessTokenResponse, error)
+	RevokePersonalAccessToken(userID int64, tokenID string) error
+	RevokeAllPersonalAccessTokens(userID int64) error
+}
+
+type AuditServiceInterface interface {
+	Record(userID int64, action, ip, userAgent string) error
+	ListForUser(userID int64) ([]AuditEntryResponse, error)
+}
+
 type ServiceManager struct {
-	UserService        UserServiceInterface
-	TokenService       TokenServiceInterface
-	PermissionsService PermissionsServiceInterface
+	UserService          UserServiceInterface
+	TokenService         TokenServiceInterface
+	PermissionsService   PermissionsServiceInterface
+	ClientService        ClientServiceInterface
+	PersonalTokenService PersonalAccessTokenServiceInterface
+	AuditService         AuditServiceInterface
 }
 
-func NewServiceManager(userService UserServiceInterface, tokenService TokenServiceInterface, permissionsService PermissionsServiceInterface) *ServiceManager {
+func NewServiceManager(userService UserServiceInterface, tokenService TokenServiceInterface, permissionsService PermissionsServiceInterface, clientService ClientServiceInterface, personalTokenService PersonalAccessTokenServiceInterface, auditService AuditServiceInterface) *ServiceManager {
 	return &ServiceManager{
-		UserService:        userService,
-		TokenService:       tokenService,
-		PermissionsService: permissionsService,
+		UserService:          userService,
+		TokenService:         tokenService,
+		PermissionsService:   permissionsService,
+		ClientService:        clientService,
+		PersonalTokenService: personalTokenService,
+		AuditService:         auditService,
 	}
 }
@@ -3,28 +3,137 @@ package service
 import (
 	"authentication-service/internal/data"
 	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v4"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+var (
+	ErrTokenRevoked    = errors.New("token has been revoked")
+	ErrWrongTokenScope = errors.New("token was not issued for this purpose")
+)
+
+// ErrInvalidClaims is wrapped by every error about a correctly signed JWT whose registered claims do not check out
+var ErrInvalidClaims = errors.New("invalid token claims")
+
+// ErrInvalidToken is wrapped by every error about a JWT that is malformed or not signed by one of our keys
+var ErrInvalidToken = errors.New("invalid token")
+
+// errSigningKeysUnavailable is wrapped by a failure to reload the key ring, which says nothing about the token
+var errSigningKeysUnavailable = errors.New("could not reload signing keys")
+
+var ErrActiveSigningKey = errors.New("the active signing key cannot be retired, rotate it first")
+
+// keyReloadInterval limits how often an unknown kid triggers a reload of the key ring
+const keyReloadInterval = 10 * time.Second
+
+// sessionTouchInterval limits how often the last use of a session is written to the database
+const sessionTouchInterval = time.Minute
+
+// Token formats. JWTs carry their claims, opaque tokens are random strings that
+// can only be resolved through the tokens table.
+const (
+	JWTFormat    = "jwt"
+	OpaqueFormat = "opaque"
+)
+
+// TokenConfig holds the settings used when minting and checking tokens
+type TokenConfig struct {
+	// Format selects whether new tokens are JWTs or opaque random strings
+	Format string
+	// KeyRetireAfter is how long a rotated signing key is still accepted for verification
+	KeyRetireAfter time.Duration
+	// ClientID identifies this service as the client that requested the tokens it issues
+	ClientID string
+	// Issuer and Audience are written to the iss and aud claims and required on every JWT when set
+	Issuer   string
+	Audience string
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat
+	Leeway time.Duration
+	// EmbedPermissions and EmbedRoles add the user's permissions and roles to access tokens
+	EmbedPermissions bool
+	EmbedRoles       bool
+	// KeyEncryptionKey encrypts signing keys before they are stored, 32 bytes for AES-256-GCM.
+	// Without it keys are stored as they are.
+	KeyEncryptionKey []byte
+}
+
 type TokenService struct {
 	RepoManager *data.RepoManager
+	KeyRing     *KeyRing
+	Config      TokenConfig
+	// UserService applies the email verification policy to embedded permissions
+	UserService UserServiceInterface
+	touches     *sessionTouches
 }
 
-func NewTokenService(repoManager *data.RepoManager) *TokenService {
-	return &TokenService{RepoManager: repoManager}
+func NewTokenService(repoManager *data.RepoManager, userService UserServiceInterface, config TokenConfig) *TokenService {
+	return &TokenService{
+		RepoManager: repoManager,
+		KeyRing:     &KeyRing{},
+		UserService: userService,
+		Config:      config,
+		touches:     &sessionTouches{touched: make(map[string]time.Time)},
+	}
 }
 
-func (s *TokenService) CreateAccessToken(userID int64, scope data.TokenScope, ttl time.Duration, secret string) (string, error) {
+func (s *TokenService) CreateAccessToken(userID int64, scope data.TokenScope, ttl time.Duration) (string, error) {
+	if s.Config.Format == OpaqueFormat {
+		return data.GenerateRandomToken()
+	}
+
+	// jti keeps tokens issued for the same user within the same second unique
+	jti, err := data.GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"sub":   userID,
 		"scope": scope,
-		"exp":   time.Now().Add(ttl).Unix(),
+		"iat":   now.Unix(),
+		"nbf":   now.Unix(),
+		"exp":   now.Add(ttl).Unix(),
+		"jti":   jti,
+	}
+	if s.Config.Issuer != "" {
+		claims["iss"] = s.Config.Issuer
+	}
+	if s.Config.Audience != "" {
+		claims["aud"] = s.Config.Audience
+	}
+	if s.Config.ClientID != "" {
+		claims["client_id"] = s.Config.ClientID
+	}
+	if scope == data.UserAccessToken {
+		err = s.embedAuthorizationClaims(userID, claims)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	secretKey := []byte(secret)
-	tokenString, err := token.SignedString(secretKey)
+	signingKey := s.KeyRing.Active()
+	if signingKey == nil {
+		// another instance may have rotated the keys and retired the one we were signing with
+		if err := s.LoadSigningKeys(); err != nil {
+			return "", err
+		}
+		signingKey = s.KeyRing.Active()
+	}
+	if signingKey == nil {
+		return "", errors.New("no active signing key")
+	}
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+	token.Header["kid"] = signingKey.ID
+	tokenString, err := token.SignedString(signingKey.signKey)
 	if err != nil {
 		return "", err
 	}
@@ -32,37 +141,320 @@ func (s *TokenService) CreateAccessToken(userID int64, scope data.TokenScope, tt
 	return tokenString, nil
 }
 
-func (s *TokenService) ValidateToken(tokenString string, secret string) (bool, error) {
-	secretKey := []byte(secret)
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+// ValidateToken checks the signature and expiry of a token and that it was issued for the expected scope
+func (s *TokenService) ValidateToken(tokenString string, scope data.TokenScope) (bool, error) {
+	if !isJWT(tokenString) {
+		_, err := s.GetActiveToken(tokenString, scope)
+		if err != nil {
+			return false, err
 		}
-		return secretKey, nil
-	})
+		return true, nil
+	}
 
+	claims, err := s.parseClaims(tokenString)
 	if err != nil {
 		return false, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		// Check if "exp" claim exists and is a valid float64
-		exp, ok := claims["exp"].(float64)
-		if !ok {
-			return false, errors.New("token does not have a valid expiration claim")
+	// A token minted for one purpose, e.g. email activation, must not be usable for another
+	if tokenScope, _ := claims["scope"].(string); tokenScope != string(scope) {
+		return false, ErrWrongTokenScope
+	}
+
+	return true, nil
+}
+
+// ExtractUserID verifies the token and returns the user ID held in its "sub" claim
+func (s *TokenService) ExtractUserID(tokenString string) (int64, error) {
+	if !isJWT(tokenString) {
+		token, err := s.RepoManager.TokenRepo.GetByHash(data.HashToken(tokenString))
+		if err != nil {
+			return 0, fmt.Errorf("invalid or expired token")
 		}
+		return token.UserID, nil
+	}
+
+	claims, err := s.parseClaims(tokenString)
+	if err != nil {
+		return 0, fmt.Errorf("invalid or expired token: %w", err)
+	}
+
+	userID, ok := claims["sub"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("userID is missing or invalid in token")
+	}
+
+	return int64(userID), nil
+}
 
-		// Convert the exp to int64 and compare with current time
-		expirationTime := int64(exp)
-		if expirationTime < time.Now().Unix() {
-			return false, errors.New("token has expired")
+// ClaimedPermissions returns the permissions embedded in an access token.
+// It reports false when the token carries no permissions claim, e.g. opaque tokens.
+func (s *TokenService) ClaimedPermissions(tokenString string) (data.Permissions, bool) {
+	if !isJWT(tokenString) {
+		return nil, false
+	}
+	claims, err := s.parseClaims(tokenString)
+	if err != nil {
+		return nil, false
+	}
+	values, ok := claims["permissions"].([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	permissions := make(data.Permissions, 0, len(values))
+	for _, value := range values {
+		if permission, ok := value.(string); ok {
+			permissions = append(permissions, permission)
 		}
+	}
+	return permissions, true
+}
 
-		return true, nil
+// embedAuthorizationClaims adds the configured permissions and roles claims. The permissions
+// claim is restricted like a lookup would be, verifiers may trust it without asking us.
+func (s *TokenService) embedAuthorizationClaims(userID int64, claims jwt.MapClaims) error {
+	if s.Config.EmbedPermissions {
+		permissions, err := s.RepoManager.PermissionsRepo.GetAllForUser(userID)
+		if err != nil {
+			return fmt.Errorf("could not retrieve user permissions: %w", err)
+		}
+		user, opErr := s.UserService.GetUserByID(userID)
+		if opErr != nil {
+			return fmt.Errorf("could not retrieve user: %w", opErr)
+		}
+		permissions = s.UserService.RestrictPermissions(user, permissions)
+		if permissions == nil {
+			// an empty claim still tells verifiers not to fall back to a lookup
+			permissions = data.Permissions{}
+		}
+		claims["permissions"] = permissions
+	}
+	if s.Config.EmbedRoles {
+		roles, err := s.RepoManager.PermissionsRepo.GetRolesForUser(userID)
+		if err != nil {
+			return fmt.Errorf("could not retrieve user roles: %w", err)
+		}
+		if roles == nil {
+			roles = []string{}
+		}
+		claims["roles"] = roles
+	}
+	return nil
+}
+
+// parseClaims verifies the signature of a JWT and its registered claims. exp is required,
+// nbf and iat are checked when present, all within the configured leeway. iss and aud are
+// required to match whenever an issuer and audience are configured.
+func (s *TokenService) parseClaims(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	// the time based claims are checked below so that the leeway applies to them
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(tokenString, claims, s.keyFunc)
+	if err != nil {
+		if errors.Is(err, errSigningKeysUnavailable) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	now := time.Now()
+	if !claims.VerifyExpiresAt(now.Add(-s.Config.Leeway).Unix(), true) {
+		return nil, fmt.Errorf("%w: token has expired", ErrInvalidClaims)
+	}
+	if !claims.VerifyNotBefore(now.Add(s.Config.Leeway).Unix(), false) {
+		return nil, fmt.Errorf("%w: token is not valid yet", ErrInvalidClaims)
+	}
+	if !claims.VerifyIssuedAt(now.Add(s.Config.Leeway).Unix(), false) {
+		return nil, fmt.Errorf("%w: token was issued in the future", ErrInvalidClaims)
+	}
+	if s.Config.Issuer != "" && !claims.VerifyIssuer(s.Config.Issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidClaims)
+	}
+	if s.Config.Audience != "" && !claims.VerifyAudience(s.Config.Audience, true) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidClaims)
 	}
 
-	return false, errors.New("invalid token")
+	return claims, nil
+}
+
+// JWKS returns the public keys other services can use to verify our tokens
+func (s *TokenService) JWKS() JWKSet {
+	return s.KeyRing.JWKS()
+}
+
+// keyFunc picks the verification key named by the token's kid header. Tokens issued
+// before key IDs were introduced carry no kid and are checked against the active key.
+func (s *TokenService) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if active := s.KeyRing.Active(); active != nil {
+			return active.keyFunc(token)
+		}
+		return nil, errors.New("no active signing key")
+	}
+
+	key, ok := s.KeyRing.Get(kid)
+	if !ok && time.Since(s.KeyRing.LoadedAt()) > keyReloadInterval {
+		// another instance may have rotated the keys since we last loaded them
+		if err := s.LoadSigningKeys(); err != nil {
+			return nil, fmt.Errorf("%w: %w", errSigningKeysUnavailable, err)
+		}
+		key, ok = s.KeyRing.Get(kid)
+	}
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	return key.keyFunc(token)
+}
+
+// LoadSigningKeys refreshes the key ring from the database. Besides being called after changes
+// made here, it runs periodically to pick up keys rotated or retired by other instances.
+func (s *TokenService) LoadSigningKeys() error {
+	models, err := s.RepoManager.SigningKeyRepo.GetAll()
+	if err != nil {
+		return err
+	}
+
+	var active *SigningKey
+	var keys []*SigningKey
+
+	for _, model := range models {
+		material, sealed, err := openKeyMaterial(s.Config.KeyEncryptionKey, model.ID, model.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("could not load signing key %s: %w", model.ID, err)
+		}
+		// keys stored before a key encryption key was configured are encrypted the first time they are loaded
+		if !sealed && s.Config.KeyEncryptionKey != nil {
+			err = s.storeKeyMaterial(model.ID, material)
+			if err != nil {
+				return fmt.Errorf("could not encrypt signing key %s: %w", model.ID, err)
+			}
+		}
+		key, err := ParseSigningKey(model.Algorithm, material)
+		if err != nil {
+			return fmt.Errorf("could not load signing key %s: %w", model.ID, err)
+		}
+		key.ID = model.ID
+		if model.RetiredAt != nil {
+			key.retireAt = *model.RetiredAt
+		}
+		if key.retired() {
+			continue
+		}
+		if model.Active {
+			active = key
+		}
+		keys = append(keys, key)
+	}
+
+	s.KeyRing.Set(active, keys)
+	return nil
+}
+
+// ImportSigningKey makes a configured key the active one. A key that is already
+// known is left alone, so restarts keep whatever an earlier rotation selected.
+func (s *TokenService) ImportSigningKey(key *SigningKey) error {
+	models, err := s.RepoManager.SigningKeyRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, model := range models {
+		if model.ID == key.ID {
+			return s.LoadSigningKeys()
+		}
+	}
+
+	return s.activateSigningKey(key)
+}
+
+// RotateSigningKey generates a new key with the same algorithm as the current one and
+// starts signing with it. The previous key keeps verifying until Config.KeyRetireAfter has passed.
+func (s *TokenService) RotateSigningKey() (*SigningKey, error) {
+	active := s.KeyRing.Active()
+	if active == nil {
+		return nil, errors.New("no active signing key")
+	}
+
+	key, err := GenerateSigningKey(active.Method.Alg())
+	if err != nil {
+		return nil, err
+	}
+	err = s.activateSigningKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// RetireSigningKey immediately stops accepting tokens signed with a previous key
+func (s *TokenService) RetireSigningKey(kid string) error {
+	if active := s.KeyRing.Active(); active != nil && active.ID == kid {
+		return ErrActiveSigningKey
+	}
+	err := s.RepoManager.SigningKeyRepo.Retire(kid, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return s.LoadSigningKeys()
+}
+
+func (s *TokenService) ListSigningKeys() ([]SigningKeyResponse, error) {
+	models, err := s.RepoManager.SigningKeyRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]SigningKeyResponse, 0, len(models))
+	for _, model := range models {
+		keys = append(keys, SigningKeyResponse{
+			ID:        model.ID,
+			Algorithm: model.Algorithm,
+			CreatedAt: model.CreatedAt,
+			Active:    model.Active,
+			RetiredAt: model.RetiredAt,
+		})
+	}
+
+	return keys, nil
+}
+
+func (s *TokenService) activateSigningKey(key *SigningKey) error {
+	material, err := s.sealKeyMaterial(key.ID, key.material)
+	if err != nil {
+		return err
+	}
+	model := &data.SigningKeyModel{
+		ID:         key.ID,
+		Algorithm:  key.Method.Alg(),
+		PrivateKey: material,
+		CreatedAt:  time.Now(),
+	}
+	err = s.RepoManager.SigningKeyRepo.Rotate(model, time.Now().Add(s.Config.KeyRetireAfter))
+	if err != nil {
+		return err
+	}
+
+	return s.LoadSigningKeys()
+}
+
+// sealKeyMaterial encrypts key material for storage when a key encryption key is configured
+func (s *TokenService) sealKeyMaterial(kid string, material []byte) ([]byte, error) {
+	if s.Config.KeyEncryptionKey == nil {
+		return material, nil
+	}
+	return sealKeyMaterial(s.Config.KeyEncryptionKey, kid, material)
+}
+
+func (s *TokenService) storeKeyMaterial(kid string, material []byte) error {
+	sealed, err := s.sealKeyMaterial(kid, material)
+	if err != nil {
+		return err
+	}
+	return s.RepoManager.SigningKeyRepo.SetPrivateKey(kid, sealed)
 }
 
 func (s *TokenService) GetTokensForUser(userID int64) ([]data.Token, error) {
@@ -84,3 +476,372 @@ func (s *TokenService) InsertToken(token *data.Token) (*data.Token, error) {
 func (s *TokenService) DeleteTokensForUser(userId int64, scope data.TokenScope) error {
 	return s.RepoManager.TokenRepo.DeleteTokensForUser(userId, scope)
 }
+
+// CreateTokenPair issues a short-lived access token together with a refresh token.
+// Both are stored under the same family so the whole login can be revoked at once.
+func (s *TokenService) CreateTokenPair(userID int64, family string, accessTTL, refreshTTL time.Duration) (*LoginInResponse, error) {
+	accessToken, err := s.CreateAccessToken(userID, data.UserAccessToken, accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.RepoManager.TokenRepo.Insert(&data.Token{
+		Hash:   data.HashToken(accessToken),
+		UserID: userID,
+		Expiry: time.Now().Add(accessTTL),
+		Scope:  data.UserAccessToken,
+		Family: family,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := data.GenerateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.RepoManager.TokenRepo.Insert(&data.Token{
+		Hash:   data.HashToken(refreshToken),
+		UserID: userID,
+		Expiry: time.Now().Add(refreshTTL),
+		Scope:  data.RefreshToken,
+		Family: family,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginInResponse{
+		SessionID:          family,
+		AuthorizationToken: accessToken,
+		RefreshToken:       refreshToken,
+		ExpiresIn:          int64(accessTTL.Seconds()),
+	}, nil
+}
+
+// UseRefreshToken consumes a refresh token so it can be rotated. Presenting a token
+// that was already used is treated as theft and revokes the whole token family.
+func (s *TokenService) UseRefreshToken(tokenString string) (*data.Token, error) {
+	token, err := s.RepoManager.TokenRepo.GetByHash(data.HashToken(tokenString))
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	if token.Scope != data.RefreshToken || time.Now().After(token.Expiry) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	consumed, err := s.RepoManager.TokenRepo.MarkUsed(token.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if token.Used || !consumed {
+		err = s.RevokeSession(token.UserID, token.Family)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	err = s.TouchSession(token.Family)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// CreateOneTimeToken issues a random single-use token of the given scope, e.g. for resetting
+// a password, replacing any token of the same scope the user requested before
+func (s *TokenService) CreateOneTimeToken(userID int64, scope data.TokenScope, ttl time.Duration) (string, error) {
+	err := s.RepoManager.TokenRepo.DeleteTokensForUser(userID, scope)
+	if err != nil {
+		return "", err
+	}
+	return s.AddOneTimeToken(userID, scope, ttl)
+}
+
+// AddOneTimeToken issues a random single-use token of the given scope next to any the user
+// already holds, for tokens that must stay usable until they expire, like email change cancellations
+func (s *TokenService) AddOneTimeToken(userID int64, scope data.TokenScope, ttl time.Duration) (string, error) {
+	tokenString, err := data.GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = s.RepoManager.TokenRepo.Insert(&data.Token{
+		Hash:   data.HashToken(tokenString),
+		UserID: userID,
+		Expiry: time.Now().Add(ttl),
+		Scope:  scope,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+// ConsumeOneTimeToken resolves a token made by CreateOneTimeToken and marks it as used in the
+// same step, so that of two requests presenting the same token only one gets it
+func (s *TokenService) ConsumeOneTimeToken(tokenString string, scope data.TokenScope) (*data.Token, error) {
+	token, err := s.GetActiveToken(tokenString, scope)
+	if err != nil {
+		return nil, err
+	}
+	consumed, err := s.RepoManager.TokenRepo.MarkUsed(token.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrTokenRevoked
+	}
+	token.Used = true
+
+	return token, nil
+}
+
+// GetActiveToken confirms a token of the given scope is still stored and unexpired. Tokens are
+// deleted when they are revoked, so a valid signature alone is not enough to trust a token.
+func (s *TokenService) GetActiveToken(tokenString string, scope data.TokenScope) (*data.Token, error) {
+	token, err := s.RepoManager.TokenRepo.GetByHash(data.HashToken(tokenString))
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, ErrTokenRevoked
+		}
+		return nil, err
+	}
+	if token.Scope != scope {
+		return nil, ErrWrongTokenScope
+	}
+	if time.Now().After(token.Expiry) {
+		return nil, ErrTokenRevoked
+	}
+
+	return token, nil
+}
+
+// RevokeToken revokes a single token together with every other token issued for the same session
+func (s *TokenService) RevokeToken(token *data.Token) error {
+	if token.Family == "" {
+		return s.RepoManager.TokenRepo.Delete(token.Hash)
+	}
+	err := s.RevokeSession(token.UserID, token.Family)
+	if errors.Is(err, data.ErrRecordNotFound) {
+		// tokens issued before sessions were recorded
+		return s.RepoManager.TokenRepo.DeleteFamily(token.UserID, token.Family)
+	}
+	return err
+}
+
+// RevokeUserTokens revokes every access and refresh token of a user, logging them out everywhere
+func (s *TokenService) RevokeUserTokens(userID int64) error {
+	err := s.RepoManager.TokenRepo.DeleteTokensForUser(userID, data.UserAccessToken)
+	if err != nil {
+		return err
+	}
+	err = s.RepoManager.TokenRepo.DeleteTokensForUser(userID, data.RefreshToken)
+	if err != nil {
+		return err
+	}
+	return s.RepoManager.SessionRepo.DeleteAllForUser(userID)
+}
+
+// CreateSession records a new login. Its ID is used as the family of the tokens issued for it.
+func (s *TokenService) CreateSession(userID int64, userAgent, ip string) (*data.Session, error) {
+	sessionID, err := data.GenerateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	return s.RepoManager.SessionRepo.Insert(&data.Session{
+		ID:         sessionID,
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	})
+}
+
+// ListSessions returns the sessions of a user, flagging the one the request was made with
+func (s *TokenService) ListSessions(userID int64, currentSessionID string) ([]SessionResponse, error) {
+	sessions, err := s.RepoManager.SessionRepo.GetAllForUser(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == currentSessionID,
+		})
+	}
+
+	return res, nil
+}
+
+// RevokeSession ends a single session of a user and revokes its tokens
+func (s *TokenService) RevokeSession(userID int64, sessionID string) error {
+	_, err := s.RepoManager.SessionRepo.GetByID(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	err = s.RepoManager.TokenRepo.DeleteFamily(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	return s.RepoManager.SessionRepo.Delete(userID, sessionID)
+}
+
+// RevokeOtherSessions revokes every session of a user except the given one
+func (s *TokenService) RevokeOtherSessions(userID int64, keepSessionID string) error {
+	sessions, err := s.RepoManager.SessionRepo.GetAllForUser(userID, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID == keepSessionID {
+			continue
+		}
+		err = s.RevokeSession(userID, session.ID)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// TouchSession records that a session was just used. A session is written at most once per
+// sessionTouchInterval, so last_seen_at is only accurate to that.
+func (s *TokenService) TouchSession(sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	now := time.Now()
+	if !s.touches.due(sessionID, now) {
+		return nil
+	}
+	return s.RepoManager.SessionRepo.Touch(sessionID, now, now.Add(-sessionTouchInterval))
+}
+
+// PruneSessions removes the sessions that no longer have a live refresh token
+func (s *TokenService) PruneSessions() (int64, error) {
+	now := time.Now()
+	return s.RepoManager.SessionRepo.DeleteDead(now, now.Add(-sessionTouchInterval))
+}
+
+// sessionTouches remembers when this process last wrote the last use of each session
+type sessionTouches struct {
+	mu      sync.Mutex
+	touched map[string]time.Time
+	sweptAt time.Time
+}
+
+// due reports whether a session used at now should be written, and if so counts it as written
+func (t *sessionTouches) due(sessionID string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.sweptAt) > sessionTouchInterval {
+		for id, touchedAt := range t.touched {
+			if now.Sub(touchedAt) >= sessionTouchInterval {
+				delete(t.touched, id)
+			}
+		}
+		t.sweptAt = now
+	}
+
+	if touchedAt, ok := t.touched[sessionID]; ok && now.Sub(touchedAt) < sessionTouchInterval {
+		return false
+	}
+	t.touched[sessionID] = now
+	return true
+}
+
+// Introspect reports on a token following RFC 7662. JWTs as well as opaque access and refresh
+// tokens are understood, anything invalid, expired or revoked is simply inactive.
+func (s *TokenService) Introspect(tokenString string) (*IntrospectionResponse, error) {
+	inactive := &IntrospectionResponse{Active: false}
+
+	if !isJWT(tokenString) {
+		return s.introspectOpaqueToken(tokenString)
+	}
+
+	claims, err := s.parseClaims(tokenString)
+	if err != nil {
+		if errors.Is(err, errSigningKeysUnavailable) {
+			return nil, err
+		}
+		return inactive, nil
+	}
+
+	_, err = s.GetActiveToken(tokenString, data.UserAccessToken)
+	if err != nil {
+		if errors.Is(err, ErrTokenRevoked) || errors.Is(err, ErrWrongTokenScope) {
+			return inactive, nil
+		}
+		return nil, err
+	}
+
+	userID, _ := claims["sub"].(float64)
+	exp, _ := claims["exp"].(float64)
+	iat, _ := claims["iat"].(float64)
+	nbf, _ := claims["nbf"].(float64)
+	iss, _ := claims["iss"].(string)
+	aud, _ := claims["aud"].(string)
+	jti, _ := claims["jti"].(string)
+	clientID, _ := claims["client_id"].(string)
+
+	return &IntrospectionResponse{
+		Active:   true,
+		Sub:      strconv.FormatInt(int64(userID), 10),
+		Scope:    string(data.UserAccessToken),
+		Exp:      int64(exp),
+		Iat:      int64(iat),
+		Nbf:      int64(nbf),
+		Iss:      iss,
+		Aud:      aud,
+		Jti:      jti,
+		ClientID: clientID,
+		UserID:   int64(userID),
+	}, nil
+}
+
+func (s *TokenService) introspectOpaqueToken(tokenString string) (*IntrospectionResponse, error) {
+	inactive := &IntrospectionResponse{Active: false}
+
+	token, err := s.RepoManager.TokenRepo.GetByHash(data.HashToken(tokenString))
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return inactive, nil
+		}
+		return nil, err
+	}
+	if token.Scope != data.UserAccessToken && token.Scope != data.RefreshToken {
+		return inactive, nil
+	}
+	if token.Used || time.Now().After(token.Expiry) {
+		return inactive, nil
+	}
+
+	return &IntrospectionResponse{
+		Active:   true,
+		Sub:      strconv.FormatInt(token.UserID, 10),
+		Scope:    string(token.Scope),
+		Exp:      token.Expiry.Unix(),
+		ClientID: s.Config.ClientID,
+		UserID:   token.UserID,
+	}, nil
+}
+
+// isJWT tells JWTs apart from opaque tokens, which are base64 and never contain a dot
+func isJWT(tokenString string) bool {
+	return strings.Count(tokenString, ".") == 2
+}
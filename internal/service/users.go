@@ -6,20 +6,57 @@ import (
 	"errors"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
+	"slices"
+	"strings"
 	"time"
 )
 
+var ErrNoPendingEmailChange = errors.New("no email change is pending")
+var ErrEmailTaken = errors.New("a user with this email address already exists")
+
+// ErrEmailChangeCancellable is returned for a new email change while the previous one can still be undone
+var ErrEmailChangeCancellable = errors.New("the previous email change can still be cancelled from the old address, try again once that period has passed")
+var ErrUserNotSuspended = errors.New("the user is not suspended")
+
+// UserConfig holds the account policies applied by the UserService
+type UserConfig struct {
+	// LockoutThreshold is the number of consecutive failed logins that lock an account, 0 disables lockouts
+	LockoutThreshold int
+	// LockoutDuration is how long the first lockout lasts, every further failure doubles it up to LockoutMaxDuration
+	LockoutDuration    time.Duration
+	LockoutMaxDuration time.Duration
+	// LockoutWindow is how long after the last failure, or the end of the lockout, the count starts over
+	LockoutWindow time.Duration
+	// EmailVerification is the policy for users who have not verified their email address,
+	// VerificationOff, VerificationGrace or VerificationStrict
+	EmailVerification string
+	// VerificationGracePeriod is how long after registering VerificationGrace lets users sign in
+	VerificationGracePeriod time.Duration
+	// UnverifiedPermissions are the only permissions users keep until they verify their email address
+	UnverifiedPermissions data.Permissions
+	// FoldEmailLocalPart lowercases the part of email addresses before the @ as well as the domain
+	FoldEmailLocalPart bool
+	// PasswordPolicy holds the rules new passwords have to follow, domain.DefaultPasswordPolicy when nil
+	PasswordPolicy *domain.PasswordPolicy
+}
+
 // RegisterUser registers a new user in the system
 type UserService struct {
 	RepoManager *data.RepoManager
+	Config      UserConfig
 }
 
 // NewUserService creates a new instance of UserService
-func NewUserService(repoManager *data.RepoManager) *UserService {
-	return &UserService{RepoManager: repoManager}
+func NewUserService(repoManager *data.RepoManager, config UserConfig) *UserService {
+	if config.PasswordPolicy == nil {
+		config.PasswordPolicy = domain.DefaultPasswordPolicy()
+	}
+	return &UserService{RepoManager: repoManager, Config: config}
 }
 
-func (uri *UserRegisterInput) IntoUserDomainModel() (*domain.UserDomainModel, *domain.OperationErrors) {
+// IntoUserDomainModel validates the input, holding the password to the given policy. A nil
+// policy accepts the password as is, for inputs carrying a password that was set before.
+func (uri *UserRegisterInput) IntoUserDomainModel(policy *domain.PasswordPolicy) (*domain.UserDomainModel, *domain.OperationErrors) {
 	validationErrors := &domain.OperationErrors{
 		Validation: make(map[string][]string),
 		Database:   make(map[string][]string),
@@ -33,7 +70,7 @@ func (uri *UserRegisterInput) IntoUserDomainModel() (*domain.UserDomainModel, *d
 	user.Email.Set(uri.Email, validationErrors)
 
 	// Validate and set the password
-	user.Password.Set(string(uri.Password), validationErrors)
+	user.Password.Set(string(uri.Password), policy, []string{uri.Email, uri.Name}, validationErrors)
 	user.CreatedAt = time.Now()
 	// If there are validation errors, return nil and the errors
 	if len(validationErrors.Validation) > 0 {
@@ -44,14 +81,29 @@ func (uri *UserRegisterInput) IntoUserDomainModel() (*domain.UserDomainModel, *d
 	return user, validationErrors
 }
 
+// normalizeEmail puts an email address in the form it is stored in, see domain.NormalizeEmail.
+// Addresses IDNA refuses are never stored, so lookups can ignore the error and simply find nothing.
+func (s *UserService) normalizeEmail(address string) (string, error) {
+	return domain.NormalizeEmail(address, s.Config.FoldEmailLocalPart)
+}
+
 func (s *UserService) RegisterUser(input *UserRegisterInput) (*UserResponse, *domain.OperationErrors) {
 
-	validateUser, operationError := input.IntoUserDomainModel()
+	email, emailErr := s.normalizeEmail(input.Email)
+	input.Email = email
+	validateUser, operationError := input.IntoUserDomainModel(s.Config.PasswordPolicy)
+	if emailErr != nil {
+		operationError.AddValidationError("email", emailErr.Error())
+	}
 
 	fmt.Printf("User Domain Model:%v\n", validateUser)
 	if len(operationError.Validation) > 0 {
 		return nil, operationError
 	}
+	if _, err := s.RepoManager.UserRepo.GetByEmail(validateUser.Email.Inner_value); err == nil {
+		operationError.AddValidationError("email", ErrEmailTaken.Error())
+		return nil, operationError
+	}
 	validateUser.Activated = false
 	validateUser.Version = 1
 	fmt.Printf("User Domain Model after adding fields:%v\n", validateUser)
@@ -79,6 +131,7 @@ func (s *UserService) GetUserByEmail(email string) (*UserResponse, *domain.Opera
 		Database:   make(map[string][]string),
 		Validation: make(map[string][]string),
 	}
+	email, _ = s.normalizeEmail(email)
 	output, err := s.RepoManager.UserRepo.GetByEmail(email)
 	if err != nil {
 		operationError.Database = make(map[string][]string)
@@ -87,11 +140,16 @@ func (s *UserService) GetUserByEmail(email string) (*UserResponse, *domain.Opera
 	}
 
 	res := &UserResponse{
-		ID:        output.ID,
-		Name:      output.Name,
-		Email:     output.Email,
-		Activated: output.Activated,
-		Password:  output.Password,
+		ID:           output.ID,
+		Name:         output.Name,
+		Email:        output.Email,
+		Activated:    output.Activated,
+		Password:     output.Password,
+		CreatedAt:    output.CreatedAt,
+		DeletedAt:    output.DeletedAt,
+		FailedLogins: output.FailedLogins,
+		LockedUntil:  output.LockedUntil,
+		Status:       output.Status,
 	}
 	return res, nil
 }
@@ -109,18 +167,34 @@ func (s *UserService) GetUserByID(userId int64) (*UserResponse, *domain.Operatio
 	}
 
 	res := &UserResponse{
-		ID:        output.ID,
-		Name:      output.Name,
-		Email:     output.Email,
-		Activated: output.Activated,
-		Password:  output.Password,
+		ID:           output.ID,
+		Name:         output.Name,
+		Email:        output.Email,
+		Activated:    output.Activated,
+		Password:     output.Password,
+		PendingEmail: output.PendingEmail,
+		CreatedAt:    output.CreatedAt,
+		Version:      output.Version,
+		DeletedAt:    output.DeletedAt,
+		FailedLogins: output.FailedLogins,
+		LockedUntil:  output.LockedUntil,
+		Status:       output.Status,
+		GivenName:    output.GivenName,
+		FamilyName:   output.FamilyName,
+		DisplayName:  output.DisplayName,
+		Locale:       output.Locale,
+		TimeZone:     output.TimeZone,
 	}
 	return res, nil
 }
 
-// UpdateUser updates an existing user in the system
-func (s *UserService) UpdateUser(input *UserRegisterInput) *domain.OperationErrors {
-	validateUser, operationError := input.IntoUserDomainModel()
+// UpdateUser updates an existing user in the system, provided they are still at the given version.
+// Invalid input is reported as *domain.OperationErrors, a user that changed in the meantime as
+// data.ErrEditConflict.
+func (s *UserService) UpdateUser(input *UserRegisterInput, version int) error {
+	input.Email, _ = s.normalizeEmail(input.Email)
+	// the password only proves who is making the change, it is not a new one
+	validateUser, operationError := input.IntoUserDomainModel(nil)
 
 	if len(operationError.Validation) > 0 {
 		return operationError
@@ -146,9 +220,14 @@ func (s *UserService) UpdateUser(input *UserRegisterInput) *domain.OperationErro
 	fmt.Printf("Match user:%v\n", isMatch)
 	if isMatch {
 		userModel.ID = fromDatabaseUser.ID
+		userModel.Activated = fromDatabaseUser.Activated
+		userModel.Version = version
 		fmt.Printf("User info to update:%v\n", userModel)
 		err = s.RepoManager.UserRepo.Update(&userModel)
 		if err != nil {
+			if errors.Is(err, data.ErrEditConflict) {
+				return err
+			}
 			operationError.AddDatabaseError("Database", err.Error())
 			return operationError
 		}
@@ -159,7 +238,8 @@ func (s *UserService) UpdateUser(input *UserRegisterInput) *domain.OperationErro
 }
 
 func (s *UserService) ValidateUser(input RegenerateEmailTokenInput) (*ReGenerateEmailTokenResponse, error) {
-	fromDatabaseUser, err := s.RepoManager.UserRepo.GetByEmail(input.Email)
+	email, _ := s.normalizeEmail(input.Email)
+	fromDatabaseUser, err := s.RepoManager.UserRepo.GetByEmail(email)
 	var response ReGenerateEmailTokenResponse
 	response.Email = input.Email
 
@@ -189,5 +269,476 @@ func (s *UserService) ValidateUser(input RegenerateEmailTokenInput) (*ReGenerate
 }
 
 func (s *UserService) UpdateUserActivationStatus(userID int64, status bool) error {
-	return s.RepoManager.UserRepo.UpdateUserActivationStatus(userID, status)
+	user, err := s.RepoManager.UserRepo.GetById(userID)
+	if err != nil {
+		return err
+	}
+	return s.RepoManager.UserRepo.UpdateUserActivationStatus(userID, status, user.Version)
+}
+
+// PasswordPolicy describes the rules new passwords have to follow
+func (s *UserService) PasswordPolicy() PasswordPolicyResponse {
+	policy := s.Config.PasswordPolicy
+	classes := policy.RequiredClasses
+	if classes == nil {
+		classes = []string{}
+	}
+	return PasswordPolicyResponse{
+		MinLength:          policy.MinLength,
+		MaxLengthBytes:     policy.MaxLength,
+		RequiredClasses:    classes,
+		RejectPersonalInfo: policy.RejectPersonalInfo,
+		DenyList:           len(policy.DenyList) > 0,
+	}
+}
+
+// ChangePassword replaces the password of a user after checking their current one
+func (s *UserService) ChangePassword(userID int64, input ChangePasswordInput) *domain.OperationErrors {
+	operationError := &domain.OperationErrors{}
+
+	user, err := s.RepoManager.UserRepo.GetById(userID)
+	if err != nil {
+		operationError.AddDatabaseError("Database", err.Error())
+		return operationError
+	}
+	pass := domain.Password{
+		PasswordHash: user.Password,
+	}
+	isMatch, err := pass.Matches(input.CurrentPassword)
+	if err != nil {
+		operationError.AddDatabaseError("Database", err.Error())
+		return operationError
+	}
+	if !isMatch {
+		operationError.AddValidationError("current_password", "current password is incorrect")
+		return operationError
+	}
+
+	return s.SetPassword(userID, input.NewPassword)
+}
+
+// ValidateNewPassword checks a password against the password policy without storing it
+func (s *UserService) ValidateNewPassword(userID int64, plainTextPassword string) *domain.OperationErrors {
+	operationError := &domain.OperationErrors{}
+
+	user, err := s.RepoManager.UserRepo.GetById(userID)
+	if err != nil {
+		operationError.AddDatabaseError("Database", err.Error())
+		return operationError
+	}
+
+	s.Config.PasswordPolicy.Validate(plainTextPassword, passwordPersonalInfo(user), operationError)
+	if len(operationError.Validation) > 0 {
+		return operationError
+	}
+	return nil
+}
+
+// passwordPersonalInfo is what the password policy keeps out of a user's password
+func passwordPersonalInfo(user *data.UserModel) []string {
+	return []string{user.Email, user.Name, user.GivenName, user.FamilyName, user.DisplayName}
+}
+
+// SetPassword validates and hashes a new password and stores it for the user
+func (s *UserService) SetPassword(userID int64, plainTextPassword string) *domain.OperationErrors {
+	operationError := &domain.OperationErrors{}
+
+	user, err := s.RepoManager.UserRepo.GetById(userID)
+	if err != nil {
+		operationError.AddDatabaseError("Database", err.Error())
+		return operationError
+	}
+
+	var password domain.Password
+	password.Set(plainTextPassword, s.Config.PasswordPolicy, passwordPersonalInfo(user), operationError)
+	if len(operationError.Validation) > 0 {
+		return operationError
+	}
+
+	err = s.RepoManager.UserRepo.UpdatePassword(userID, password.PasswordHash, user.Version)
+	if err != nil {
+		operationError.AddDatabaseError("Database", err.Error())
+		return operationError
+	}
+
+	return nil
+}
+
+// RequestEmailChange stores a new email address as pending after checking the user's password.
+// The returned user still carries the current address.
+func (s *UserService) RequestEmailChange(userID int64, input ChangeEmailInput) (*UserResponse, *domain.OperationErrors) {
+	operationError := &domain.OperationErrors{}
+
+	user, err := s.RepoManager.UserRepo.GetById(userID)
+	if err != nil {
+		operationError.AddDatabaseError("Database", err.Error())
+		return nil, operationError
+	}
+	pass := domain.Password{
+		PasswordHash: user.Password,
+	}
+	isMatch, err := pass.Matches(input.Password)
+	if err != nil {
+		operationError.AddDatabaseError("Database", err.Error())
+		return nil, operationError
+	}
+	if !isMatch {
+		operationError.AddValidationError("password", "password is incorrect")
+		return nil, operationError
+	}
+	// Another change now would forget the previous address and hand the next cancellation
+	// link to whoever made the first change, the owner of the old address keeps the last word
+	cancellable, err := s.emailChangeCancellable(user)
+	if err != nil {
+		operationError.AddDatabaseError("Database", err.Error())
+		return nil, operationError
+	}
+	if cancellable {
+		operationError.AddValidationError("new_email", ErrEmailChangeCancellable.Error())
+		return nil, operationError
+	}
+
+	var validated domain.UserDomainModel
+	newEmail, err := s.normalizeEmail(input.NewEmail)
+	if err != nil {
+		operationError.AddValidationError("email", err.Error())
+		return nil, operationError
+	}
+	validated.Email.Set(newEmail, operationError)
+	if len(operationError.Validation) > 0 {
+		return nil, operationError
+	}
+	if validated.Email.Inner_value == user.Email {
+		operationError.AddValidationError("new_email", "new email must differ from the current one")
+		return nil, operationError
+	}
+	if _, err := s.RepoManager.UserRepo.GetByEmail(validated.Email.Inner_value); err == nil {
+		operationError.AddValidationError("new_email", ErrEmailTaken.Error())
+		return nil, operationError
+	}
+
+	err = s.RepoManager.UserRepo.SetPendingEmail(userID, validated.Email.Inner_value)
+	if err != nil {
+		operationError.AddDatabaseError("Database", err.Error())
+		return nil, operationError
+	}
+
+	return &UserResponse{
+		ID:           user.ID,
+		Name:         user.Name,
+		Email:        user.Email,
+		PendingEmail: validated.Email.Inner_value,
+		Activated:    user.Activated,
+	}, nil
+}
+
+// ConfirmEmailChange switches the user over to their verified pending email address
+// emailChangeCancellable reports whether a confirmed email change can still be undone from the
+// previous address, which is the case as long as a cancellation token is outstanding
+func (s *UserService) emailChangeCancellable(user *data.UserModel) (bool, error) {
+	if user.PreviousEmail == "" {
+		return false, nil
+	}
+	tokens, err := s.RepoManager.TokenRepo.GetByUserIDAndScope(user.ID, data.EmailChangeCancelToken)
+	if err != nil {
+		return false, err
+	}
+	for _, token := range tokens {
+		if !token.Used && time.Now().Before(token.Expiry) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *UserService) ConfirmEmailChange(userID int64) (*UserResponse, error) {
+	user, err := s.RepoManager.UserRepo.GetById(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.PendingEmail == "" {
+		return nil, ErrNoPendingEmailChange
+	}
+	// the address may have been registered by someone else in the meantime
+	if _, err := s.RepoManager.UserRepo.GetByEmail(user.PendingEmail); err == nil {
+		return nil, ErrEmailTaken
+	}
+
+	err = s.RepoManager.UserRepo.ConfirmPendingEmail(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not change email: %w", err)
+	}
+
+	return &UserResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.PendingEmail,
+		Activated: true,
+	}, nil
+}
+
+// CancelEmailChange drops the pending email address of a user, or gives them their previous
+// address back when the change was already confirmed
+func (s *UserService) CancelEmailChange(userID int64) error {
+	user, err := s.RepoManager.UserRepo.GetById(userID)
+	if err != nil {
+		return err
+	}
+	if user.PendingEmail != "" {
+		return s.RepoManager.UserRepo.SetPendingEmail(userID, "")
+	}
+	if user.PreviousEmail == "" {
+		return ErrNoPendingEmailChange
+	}
+
+	// the change was already confirmed, give the owner their old address back
+	if owner, err := s.RepoManager.UserRepo.GetByEmail(user.PreviousEmail); err == nil && owner.ID != userID {
+		return ErrEmailTaken
+	}
+	return s.RepoManager.UserRepo.RestorePreviousEmail(userID)
+}
+
+// UpdateProfile applies a partial update to the profile of a user, provided it is still at the
+// given version. Invalid input is reported as *domain.OperationErrors, a profile that changed in
+// the meantime as data.ErrEditConflict.
+func (s *UserService) UpdateProfile(userID int64, version int, input UpdateProfileInput) error {
+	operationError := &domain.OperationErrors{}
+
+	user, err := s.RepoManager.UserRepo.GetById(userID)
+	if err != nil {
+		return err
+	}
+	if user.Version != version {
+		return data.ErrEditConflict
+	}
+
+	var validated domain.UserDomainModel
+	if input.Name != nil {
+		validated.Name.Set(*input.Name, operationError)
+		user.Name = validated.Name.Inner_value
+	}
+	if input.GivenName != nil {
+		validated.GivenName.Set("given_name", *input.GivenName, operationError)
+		user.GivenName = validated.GivenName.Inner_value
+	}
+	if input.FamilyName != nil {
+		validated.FamilyName.Set("family_name", *input.FamilyName, operationError)
+		user.FamilyName = validated.FamilyName.Inner_value
+	}
+	if input.DisplayName != nil {
+		validated.DisplayName.Set(*input.DisplayName, operationError)
+		user.DisplayName = validated.DisplayName.Inner_value
+	}
+	if input.Locale != nil {
+		validated.Locale.Set(*input.Locale, operationError)
+		user.Locale = validated.Locale.Inner_value
+	}
+	if input.TimeZone != nil {
+		validated.TimeZone.Set(*input.TimeZone, operationError)
+		user.TimeZone = validated.TimeZone.Inner_value
+	}
+	if len(operationError.Validation) > 0 {
+		return operationError
+	}
+
+	return s.RepoManager.UserRepo.UpdateProfile(user)
+}
+
+// maxPageSize caps how many users a single page of ListUsers may hold
+const maxPageSize = 100
+
+// ListUsers returns one page of the users matching the filter
+func (s *UserService) ListUsers(filter data.UserFilter) ([]UserSummary, Metadata, *domain.OperationErrors) {
+	operationError := &domain.OperationErrors{}
+
+	if filter.Page < 1 || filter.Page > 10_000_000 {
+		operationError.AddValidationError("page", "must be between 1 and 10000000")
+	}
+	if filter.PageSize < 1 || filter.PageSize > maxPageSize {
+		operationError.AddValidationError("page_size", fmt.Sprintf("must be between 1 and %d", maxPageSize))
+	}
+	if filter.Sort != "" && !slices.Contains(data.UserSortColumns, strings.TrimPrefix(filter.Sort, "-")) {
+		operationError.AddValidationError("sort", "must be one of "+strings.Join(data.UserSortColumns, ", ")+", optionally prefixed with -")
+	}
+	if filter.Status != "" && !slices.Contains(data.UserStatuses, filter.Status) {
+		operationError.AddValidationError("status", "must be one of pending, active, suspended, deleted")
+	}
+	if filter.CreatedAfter != nil && filter.CreatedBefore != nil && !filter.CreatedAfter.Before(*filter.CreatedBefore) {
+		operationError.AddValidationError("created_before", "must be later than created_after")
+	}
+	if len(operationError.Validation) > 0 {
+		return nil, Metadata{}, operationError
+	}
+
+	models, total, err := s.RepoManager.UserRepo.GetAll(filter)
+	if err != nil {
+		operationError.AddDatabaseError("Database", err.Error())
+		return nil, Metadata{}, operationError
+	}
+
+	users := make([]UserSummary, 0, len(models))
+	for _, model := range models {
+		users = append(users, UserSummary{
+			ID:               model.ID,
+			Name:             model.Name,
+			Email:            model.Email,
+			Activated:        model.Activated,
+			CreatedAt:        model.CreatedAt,
+			Version:          model.Version,
+			Status:           model.Status,
+			DeletedAt:        model.DeletedAt,
+			SuspendedAt:      model.SuspendedAt,
+			SuspensionReason: model.SuspensionReason,
+		})
+	}
+
+	return users, calculateMetadata(total, filter.Page, filter.PageSize), nil
+}
+
+func calculateMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     (totalRecords + pageSize - 1) / pageSize,
+		TotalRecords: totalRecords,
+	}
+}
+
+// CheckPassword reports whether the password is the user's current one
+func (s *UserService) CheckPassword(userID int64, plainTextPassword string) (bool, error) {
+	user, err := s.RepoManager.UserRepo.GetById(userID)
+	if err != nil {
+		return false, err
+	}
+	pass := domain.Password{
+		PasswordHash: user.Password,
+	}
+	return pass.Matches(plainTextPassword)
+}
+
+// ScheduleDeletion marks a user as deleted. The account is only removed for good by
+// PurgeDeletedUsers once the grace period has passed, until then it can be restored.
+// It returns data.ErrEditConflict when the user is no longer at the given version.
+func (s *UserService) ScheduleDeletion(userID int64, version int) error {
+	now := time.Now()
+	return s.RepoManager.UserRepo.MarkDeleted(userID, &now, version)
+}
+
+// RestoreUser cancels a scheduled deletion of the user at the given version
+func (s *UserService) RestoreUser(userID int64, version int) error {
+	return s.RepoManager.UserRepo.MarkDeleted(userID, nil, version)
+}
+
+// NormalizeStoredEmails rewrites the email and pending email addresses stored before emails were
+// normalized. It runs at every startup and finds nothing to do once all of them are normalized.
+// Addresses several users would end up sharing, and addresses IDNA refuses, are left alone and
+// reported so they can be resolved by hand.
+func (s *UserService) NormalizeStoredEmails() (*EmailNormalizationReport, error) {
+	emails, err := s.RepoManager.UserRepo.GetAllEmails()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &EmailNormalizationReport{Duplicates: make(map[string][]int64)}
+	owners := make(map[string][]int64)
+	for _, stored := range emails {
+		normalized, _ := s.normalizeEmail(stored.Email)
+		owners[normalized] = append(owners[normalized], stored.ID)
+	}
+
+	for _, stored := range emails {
+		email, err := s.normalizeEmail(stored.Email)
+		if err != nil {
+			report.Invalid = append(report.Invalid, stored.ID)
+			email = stored.Email
+		} else if len(owners[email]) > 1 {
+			report.Duplicates[email] = owners[email]
+			email = stored.Email
+		}
+		// a pending address is checked against the other users when the change is confirmed,
+		// one IDNA refuses could never be confirmed and is dropped
+		pendingEmail := stored.PendingEmail
+		if pendingEmail != "" {
+			pendingEmail, err = s.normalizeEmail(stored.PendingEmail)
+			if err != nil {
+				pendingEmail = ""
+			}
+		}
+		if email == stored.Email && pendingEmail == stored.PendingEmail {
+			continue
+		}
+
+		err = s.RepoManager.UserRepo.UpdateEmails(stored.ID, email, pendingEmail)
+		if err != nil {
+			return report, fmt.Errorf("could not normalize email of user %d: %w", stored.ID, err)
+		}
+		report.Updated++
+	}
+
+	return report, nil
+}
+
+// SuspendUser blocks a user from logging in or using their tokens until they are reinstated.
+// It returns data.ErrEditConflict when the user is no longer at the given version.
+func (s *UserService) SuspendUser(userID int64, version int, input SuspendUserInput) error {
+	operationError := &domain.OperationErrors{}
+
+	reason := strings.TrimSpace(input.Reason)
+	if reason == "" {
+		operationError.AddValidationError("reason", "must be provided")
+	}
+	if len(reason) > 500 {
+		operationError.AddValidationError("reason", "must not be more than 500 bytes long")
+	}
+	if len(operationError.Validation) > 0 {
+		return operationError
+	}
+
+	now := time.Now()
+	return s.RepoManager.UserRepo.SetSuspended(userID, &now, reason, version)
+}
+
+// ReinstateUser lifts the suspension of the user at the given version
+func (s *UserService) ReinstateUser(userID int64, version int) error {
+	user, err := s.RepoManager.UserRepo.GetById(userID)
+	if err != nil {
+		return err
+	}
+	if user.SuspendedAt == nil {
+		return ErrUserNotSuspended
+	}
+	return s.RepoManager.UserRepo.SetSuspended(userID, nil, "", version)
+}
+
+// DeleteUser permanently removes a user together with their tokens, sessions and permissions.
+// Their audit log is kept under the bare user ID, stripped of IP addresses and user agents.
+// It returns data.ErrEditConflict when the user is no longer at the given version.
+func (s *UserService) DeleteUser(userID int64, version int) error {
+	return s.RepoManager.UserRepo.Delete(userID, version)
+}
+
+// PurgeDeletedUsers permanently removes every user scheduled for deletion before the given time.
+// Users restored while the purge runs are left alone.
+func (s *UserService) PurgeDeletedUsers(before time.Time) (int, error) {
+	users, err := s.RepoManager.UserRepo.GetDeletedBefore(before)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, user := range users {
+		err := s.DeleteUser(user.ID, user.Version)
+		if errors.Is(err, data.ErrEditConflict) {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
 }
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"authentication-service/internal/data"
+	"errors"
+	"time"
+)
+
+// Email verification policies
+const (
+	// VerificationOff lets unverified users do everything verified users can
+	VerificationOff = "off"
+	// VerificationGrace lets unverified users sign in with restricted permissions for a while after registering
+	VerificationGrace = "grace"
+	// VerificationStrict refuses to sign in unverified users
+	VerificationStrict = "strict"
+)
+
+// StepVerifyEmail is the PendingStep of users who have not verified their email address
+const StepVerifyEmail = "verify_email"
+
+var (
+	ErrEmailNotVerified         = errors.New("the email address has not been verified")
+	ErrVerificationGraceExpired = errors.New("the grace period for verifying the email address has ended")
+)
+
+// EmailVerificationStatus applies the email verification policy to a user. It returns the
+// verification step when the user still has to take it, together with an error when the
+// user may not sign in until then. Without an error the user is restricted to
+// UnverifiedPermissions until the returned deadline.
+func (s *UserService) EmailVerificationStatus(user *UserResponse) (*PendingStep, error) {
+	if user.Activated || s.Config.EmailVerification == VerificationOff {
+		return nil, nil
+	}
+
+	step := &PendingStep{
+		Step:        StepVerifyEmail,
+		Description: "Verify your email address with the token that was sent to it, POST /v1/tokens/email sends a new one",
+	}
+	if s.Config.EmailVerification == VerificationStrict {
+		return step, ErrEmailNotVerified
+	}
+
+	deadline := user.CreatedAt.Add(s.Config.VerificationGracePeriod)
+	step.Deadline = &deadline
+	if !time.Now().Before(deadline) {
+		return step, ErrVerificationGraceExpired
+	}
+	return step, nil
+}
+
+// RestrictPermissions limits the permissions of a user who has yet to verify their email
+// address to the ones granted to unverified users
+func (s *UserService) RestrictPermissions(user *UserResponse, permissions data.Permissions) data.Permissions {
+	if user.Activated || s.Config.EmailVerification == VerificationOff {
+		return permissions
+	}
+
+	restricted := data.Permissions{}
+	for _, permission := range permissions {
+		if s.Config.UnverifiedPermissions.HasPermission(permission) {
+			restricted = append(restricted, permission)
+		}
+	}
+	return restricted
+}
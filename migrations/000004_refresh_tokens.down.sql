@@ -0,0 +1,2 @@
+ALTER TABLE tokens DROP COLUMN used;
+ALTER TABLE tokens DROP COLUMN family;
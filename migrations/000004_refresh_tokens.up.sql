@@ -0,0 +1,2 @@
+ALTER TABLE tokens ADD COLUMN family TEXT NOT NULL DEFAULT '';
+ALTER TABLE tokens ADD COLUMN used BOOLEAN NOT NULL DEFAULT 0 CHECK (used IN (0, 1));
@@ -0,0 +1,2 @@
+DELETE FROM permissions WHERE permission = 'keys:write';
+DROP TABLE IF EXISTS signing_keys;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS signing_keys (
+    kid TEXT NOT NULL PRIMARY KEY,
+    algorithm TEXT NOT NULL,
+    private_key BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    active BOOLEAN NOT NULL DEFAULT 0 CHECK (active IN (0, 1)),
+    retired_at DATETIME
+);
+
+INSERT INTO permissions (permission)
+values('keys:write');
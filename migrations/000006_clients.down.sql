@@ -0,0 +1,2 @@
+DELETE FROM permissions WHERE permission = 'clients:write';
+DROP TABLE IF EXISTS clients;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS clients (
+    id TEXT NOT NULL PRIMARY KEY,
+    name TEXT NOT NULL,
+    secret_hash BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO permissions (permission)
+values('clients:write');
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS users_roles;
+DROP TABLE IF EXISTS roles;
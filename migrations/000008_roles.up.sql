@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS roles (
+                                     id integer PRIMARY KEY AUTOINCREMENT,
+                                     role text unique NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS users_roles (
+                                           user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+                                           role_id bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+                                           PRIMARY KEY (user_id, role_id)
+);
@@ -0,0 +1,2 @@
+DELETE FROM tokens WHERE scope IN ('EmailChangeToken', 'EmailChangeCancelToken');
+ALTER TABLE users DROP COLUMN pending_email;
@@ -0,0 +1,2 @@
+DELETE FROM users_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE permission = 'users:read');
+DELETE FROM permissions WHERE permission = 'users:read';
@@ -0,0 +1,2 @@
+INSERT INTO permissions (permission)
+values('users:read');
@@ -0,0 +1,4 @@
+DELETE FROM users_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE permission = 'users:write');
+DELETE FROM permissions WHERE permission = 'users:write';
+DROP TABLE IF EXISTS audit_log;
+ALTER TABLE users DROP COLUMN deleted_at;
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    action TEXT NOT NULL,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_user_id_idx ON audit_log (user_id);
+
+INSERT INTO permissions (permission)
+values('users:write');
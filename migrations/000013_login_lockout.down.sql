@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN locked_until;
+ALTER TABLE users DROP COLUMN last_failed_login;
+ALTER TABLE users DROP COLUMN failed_logins;
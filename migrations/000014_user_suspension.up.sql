@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN suspended_at DATETIME;
+ALTER TABLE users ADD COLUMN suspension_reason TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN previous_email TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,17 @@
+-- Audit entries outlive the users they are about, user_id is kept as a bare ID
+CREATE TABLE audit_log_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id bigint NOT NULL,
+    action TEXT NOT NULL,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO audit_log_new (id, user_id, action, ip, user_agent, created_at)
+SELECT id, user_id, action, ip, user_agent, created_at FROM audit_log;
+
+DROP TABLE audit_log;
+ALTER TABLE audit_log_new RENAME TO audit_log;
+
+CREATE INDEX IF NOT EXISTS audit_log_user_id_idx ON audit_log (user_id);